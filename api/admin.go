@@ -0,0 +1,415 @@
+package api
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"xcodeengine/model"
+	"xcodeengine/problems"
+	"xcodeengine/service"
+)
+
+// maxPackageUpload caps the size of an uploaded problem package and of any other
+// admin request body, problem documents and tests included
+const maxPackageUpload = 64 << 20
+
+// adminToken guards the admin endpoints; they are disabled while it is empty
+var adminToken string
+
+// SetAdminToken sets the bearer token the admin endpoints require
+func SetAdminToken(token string) {
+	adminToken = token
+}
+
+// ImportResponse reports an imported problem
+type ImportResponse struct {
+	ID       string   `json:"id"`
+	Version  int      `json:"version"`
+	Tests    int      `json:"tests"`
+	Warnings []string `json:"warnings,omitempty"`
+	// Validation is the validator's verdict on the imported tests
+	Validation *model.InputValidationReport `json:"validation"`
+}
+
+// EditResponse reports a problem after an edit, with its validator's verdict on the
+// test inputs
+type EditResponse struct {
+	Problem    model.ProblemDocument        `json:"problem"`
+	Validation *model.InputValidationReport `json:"validation"`
+}
+
+// requireAdmin checks the request's bearer token and writes the error response when
+// it does not match
+func requireAdmin(w http.ResponseWriter, r *http.Request) bool {
+	if adminToken == "" {
+		http.Error(w, "admin API disabled", http.StatusNotFound)
+		return false
+	}
+	token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+	if !ok || subtle.ConstantTimeCompare([]byte(token), []byte(adminToken)) != 1 {
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return false
+	}
+	return true
+}
+
+// adminHandler wraps an admin endpoint with CORS handling and the token check
+func adminHandler(handler http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		setCORSHeaders(w)
+		if r.Method == http.MethodOptions {
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+		if !requireAdmin(w, r) {
+			return
+		}
+		handler(w, r)
+	}
+}
+
+// writeStoreError maps a problem store error to its HTTP status
+func writeStoreError(w http.ResponseWriter, err error) {
+	status := http.StatusBadRequest
+	switch {
+	case errors.Is(err, problems.ErrProblemNotFound), errors.Is(err, problems.ErrVersionNotFound), errors.Is(err, problems.ErrTestNotFound):
+		status = http.StatusNotFound
+	case errors.Is(err, problems.ErrProblemExists), errors.Is(err, problems.ErrReadOnly):
+		status = http.StatusConflict
+	}
+	http.Error(w, err.Error(), status)
+}
+
+// writeEdited validates the inputs of a problem that was just saved and writes it
+// with the report. A published problem whose inputs fail goes back to being a draft;
+// one held back for validation goes live once they pass.
+func writeEdited(w http.ResponseWriter, compilerService *service.CompilerService, problem *model.Problem, status int) {
+	report := compilerService.ValidateInputs(problem)
+	if report.Valid && problem.PendingValidation {
+		if released, ok := problems.Active().Get(problem.ID); ok {
+			problem = released
+		}
+	}
+	if !report.Valid && (!problem.Draft || problem.PendingValidation) {
+		withdrawn, err := problems.Active().SetDraft(problem.ID, true)
+		if err != nil {
+			writeStoreError(w, err)
+			return
+		}
+		problem = withdrawn
+	}
+	writeJSON(w, status, EditResponse{Problem: problem.Document(), Validation: report})
+}
+
+// problemsHandler lists every problem in full (GET) or creates a draft (POST)
+func problemsHandler(compilerService *service.CompilerService) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		store := problems.Active()
+		switch r.Method {
+		case http.MethodGet:
+			all := store.All()
+			docs := make([]model.ProblemDocument, 0, len(all))
+			for _, p := range all {
+				docs = append(docs, p.Document())
+			}
+			writeJSON(w, http.StatusOK, docs)
+		case http.MethodPost:
+			var doc model.ProblemDocument
+			if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxPackageUpload)).Decode(&doc); err != nil {
+				http.Error(w, "invalid request body", http.StatusBadRequest)
+				return
+			}
+			doc.Draft = true
+			created, err := store.Create(doc.ToProblem())
+			if err != nil {
+				writeStoreError(w, err)
+				return
+			}
+			writeEdited(w, compilerService, created, http.StatusCreated)
+		default:
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		}
+	}
+}
+
+// problemHandler reads (GET), replaces (PUT) or deletes (DELETE) one problem. A
+// replacement keeps the problem's published state; publishing goes through /publish.
+func problemHandler(compilerService *service.CompilerService) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		store := problems.Active()
+		id := r.PathValue("id")
+		switch r.Method {
+		case http.MethodGet:
+			problem, ok := store.Get(id)
+			if !ok {
+				writeStoreError(w, problems.ErrProblemNotFound)
+				return
+			}
+			writeJSON(w, http.StatusOK, problem.Document())
+		case http.MethodPut:
+			var doc model.ProblemDocument
+			if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxPackageUpload)).Decode(&doc); err != nil {
+				http.Error(w, "invalid request body", http.StatusBadRequest)
+				return
+			}
+			current, ok := store.Get(id)
+			if !ok {
+				writeStoreError(w, problems.ErrProblemNotFound)
+				return
+			}
+			doc.ID, doc.Draft = id, current.Draft
+			updated, err := store.Update(doc.ToProblem())
+			if err != nil {
+				writeStoreError(w, err)
+				return
+			}
+			writeEdited(w, compilerService, updated, http.StatusOK)
+		case http.MethodDelete:
+			if err := store.Delete(id); err != nil {
+				writeStoreError(w, err)
+				return
+			}
+			w.WriteHeader(http.StatusNoContent)
+		default:
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		}
+	}
+}
+
+// publishHandler publishes a draft once its validator accepts every test input;
+// otherwise it answers 422 with the validator's report
+func publishHandler(compilerService *service.CompilerService) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		store := problems.Active()
+		problem, ok := store.Get(r.PathValue("id"))
+		if !ok {
+			writeStoreError(w, problems.ErrProblemNotFound)
+			return
+		}
+		report := compilerService.ValidateInputs(problem)
+		if !report.Valid {
+			writeJSON(w, http.StatusUnprocessableEntity, EditResponse{Problem: problem.Document(), Validation: report})
+			return
+		}
+		published, err := store.SetDraft(problem.ID, false)
+		if err != nil {
+			writeStoreError(w, err)
+			return
+		}
+		writeJSON(w, http.StatusOK, EditResponse{Problem: published.Document(), Validation: report})
+	}
+}
+
+// validateHandler runs a problem's validator over its test inputs without changing it
+func validateHandler(compilerService *service.CompilerService) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		problem, ok := problems.Active().Get(r.PathValue("id"))
+		if !ok {
+			writeStoreError(w, problems.ErrProblemNotFound)
+			return
+		}
+		writeJSON(w, http.StatusOK, compilerService.ValidateInputs(problem))
+	}
+}
+
+// archiveHandler archives a problem, or restores it when restore is set
+func archiveHandler(restore bool) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		problem, err := problems.Active().SetArchived(r.PathValue("id"), !restore)
+		if err != nil {
+			writeStoreError(w, err)
+			return
+		}
+		writeJSON(w, http.StatusOK, problem.Document())
+	}
+}
+
+// testHandler uploads (PUT) or removes (DELETE) the 1-based test n of a problem; a
+// PUT one past the last test appends it
+func testHandler(compilerService *service.CompilerService) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		n, err := strconv.Atoi(r.PathValue("n"))
+		if err != nil {
+			http.Error(w, "invalid test number", http.StatusBadRequest)
+			return
+		}
+		store := problems.Active()
+		id := r.PathValue("id")
+
+		var problem *model.Problem
+		switch r.Method {
+		case http.MethodPut:
+			var tc model.TestCase
+			if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxPackageUpload)).Decode(&tc); err != nil {
+				http.Error(w, "invalid request body", http.StatusBadRequest)
+				return
+			}
+			problem, err = store.PutTest(id, n, tc)
+		case http.MethodDelete:
+			problem, err = store.DeleteTest(id, n)
+		default:
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		if err != nil {
+			writeStoreError(w, err)
+			return
+		}
+		writeEdited(w, compilerService, problem, http.StatusOK)
+	}
+}
+
+// versionsHandler lists the recorded versions of a problem
+func versionsHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	versions, err := problems.Active().Versions(r.PathValue("id"))
+	if err != nil {
+		writeStoreError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, versions)
+}
+
+// versionHandler returns one recorded version of a problem
+func versionHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	version, err := strconv.Atoi(r.PathValue("version"))
+	if err != nil {
+		http.Error(w, "invalid version", http.StatusBadRequest)
+		return
+	}
+	problem, err := problems.Active().GetVersion(r.PathValue("id"), version)
+	if err != nil {
+		writeStoreError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, problem.Document())
+}
+
+// generateOutputsHandler runs a problem's reference solution over its tests and saves
+// the outputs as the expected ones
+func generateOutputsHandler(compilerService *service.CompilerService) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		resp, err := compilerService.GenerateExpectedOutputs(r.PathValue("id"))
+		if errors.Is(err, service.ErrProblemNotFound) {
+			err = problems.ErrProblemNotFound
+		}
+		if err != nil {
+			writeStoreError(w, err)
+			return
+		}
+		writeJSON(w, http.StatusOK, resp)
+	}
+}
+
+// generateTestsHandler runs a problem's test script, then checks the new inputs with
+// its validator; a published problem whose inputs fail is withdrawn to a draft
+func generateTestsHandler(compilerService *service.CompilerService) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		resp, err := compilerService.GenerateTests(r.PathValue("id"))
+		if errors.Is(err, service.ErrProblemNotFound) {
+			err = problems.ErrProblemNotFound
+		}
+		if err != nil {
+			writeStoreError(w, err)
+			return
+		}
+		problem, ok := problems.Active().Get(resp.ProblemID)
+		if !ok {
+			writeStoreError(w, problems.ErrProblemNotFound)
+			return
+		}
+		resp.Validation = compilerService.ValidateInputs(problem)
+		if !resp.Validation.Valid && !problem.Draft {
+			withdrawn, err := problems.Active().SetDraft(problem.ID, true)
+			if err != nil {
+				writeStoreError(w, err)
+				return
+			}
+			resp.ProblemVersion = withdrawn.Version
+		}
+		writeJSON(w, http.StatusOK, resp)
+	}
+}
+
+// importProblemHandler takes a zipped Polygon or Kattis package as the request body
+// and adds it to the problems directory as a draft, with its test inputs validated.
+// Query parameters: format, id, and force to replace an existing problem.
+func importProblemHandler(compilerService *service.CompilerService) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+
+		data, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxPackageUpload))
+		if err != nil {
+			http.Error(w, "package too large or unreadable", http.StatusRequestEntityTooLarge)
+			return
+		}
+		pkg, name, err := problems.OpenPackage(data)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		query := r.URL.Query()
+		problem, warnings, err := problems.Import(pkg, name, query.Get("format"), query.Get("id"))
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		problem.Draft = true
+		store := problems.Active()
+		if _, exists := store.Get(problem.ID); exists && query.Get("force") == "true" {
+			problem, err = store.Update(*problem)
+		} else {
+			problem, err = store.Create(*problem)
+		}
+		if err != nil {
+			writeStoreError(w, err)
+			return
+		}
+
+		report := compilerService.ValidateInputs(problem)
+		writeJSON(w, http.StatusCreated, ImportResponse{
+			ID:         problem.ID,
+			Version:    problem.Version,
+			Tests:      len(problem.TestCases),
+			Warnings:   warnings,
+			Validation: report,
+		})
+	}
+}
@@ -11,16 +11,16 @@ import (
 	"xcodeengine/model"
 	"xcodeengine/problems"
 	"xcodeengine/service"
-
-	compilergrpc "github.com/lijuuu/GlobalProtoXcode/Compiler"
 )
 
 // ExecuteRequest captures payloads from the UI.
 type ExecuteRequest struct {
-	Code     string `json:"code"`
-	Language string `json:"language"`
-	Mode     string `json:"mode"` // "problem" for relaxed limits, anything else is standard
-	Input    string `json:"input"`
+	Code     string   `json:"code"`
+	Language string   `json:"language"`
+	Mode     string   `json:"mode"` // "problem" for relaxed limits, anything else is standard
+	Input    string   `json:"input"`
+	Flags    []string `json:"flags,omitempty"`
+	model.ProjectFiles
 }
 
 // ExecuteResponse mirrors the compiler response with HTTP friendly error reporting.
@@ -30,6 +30,7 @@ type ExecuteResponse struct {
 	StatusMessage string `json:"status_message"`
 	Success       bool   `json:"success"`
 	ExecutionTime string `json:"execution_time,omitempty"`
+	Version       string `json:"version,omitempty"`
 }
 
 // StartServer boots a simple HTTP server that exposes the execution API and serves the static UI.
@@ -56,21 +57,22 @@ func StartServer(addr string, workerPool *executor.WorkerPool) {
 		req.Language = strings.TrimSpace(req.Language)
 		req.Code = strings.TrimSpace(req.Code)
 
-		if req.Language == "" || req.Code == "" {
-			http.Error(w, "code and language are required", http.StatusBadRequest)
+		hasProject := len(req.Files) > 0 || req.Archive != ""
+		if req.Language == "" || (req.Code == "" && !hasProject) {
+			http.Error(w, "code (or files) and language are required", http.StatusBadRequest)
 			return
 		}
 
 		var (
-			resp *compilergrpc.CompileResponse
+			resp *service.CompileResult
 			err  error
 		)
 
 		if strings.EqualFold(req.Mode, "problem") {
-			resp, err = compilerService.ExecuteProblemCode(req.Code, req.Language)
+			resp, err = compilerService.ExecuteProblemCode(req.Code, req.Language, req.Flags, req.ProjectFiles)
 		} else {
 			encoded := base64.StdEncoding.EncodeToString([]byte(req.Code))
-			resp, err = compilerService.Compile(encoded, req.Language, req.Input)
+			resp, err = compilerService.Compile(encoded, req.Language, req.Input, req.Flags, req.ProjectFiles)
 		}
 		if err != nil {
 			http.Error(w, err.Error(), http.StatusInternalServerError)
@@ -83,9 +85,27 @@ func StartServer(addr string, workerPool *executor.WorkerPool) {
 			StatusMessage: resp.StatusMessage,
 			Success:       resp.Success,
 			ExecutionTime: resp.ExecutionTime,
+			Version:       resp.Version,
 		})
 	})
 
+	mux.HandleFunc("/api/execute/stream", executeStreamHandler(compilerService))
+
+	mux.HandleFunc("/api/languages", func(w http.ResponseWriter, r *http.Request) {
+		setCORSHeaders(w)
+		if r.Method == http.MethodOptions {
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+		if r.Method != http.MethodGet {
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		writeJSON(w, http.StatusOK, workerPool.Languages())
+	})
+
+	mux.Handle("/api/sessions", sessionHandler(compilerService))
+
 	mux.HandleFunc("/api/problems", func(w http.ResponseWriter, r *http.Request) {
 		setCORSHeaders(w)
 		if r.Method == http.MethodOptions {
@@ -120,7 +140,7 @@ func StartServer(addr string, workerPool *executor.WorkerPool) {
 			return
 		}
 
-		resp, err := compilerService.JudgeProblem(req.Code, req.Language, req.ProblemID)
+		resp, err := compilerService.JudgeSubmission(req, nil)
 		if err != nil {
 			http.Error(w, err.Error(), http.StatusBadRequest)
 			return
@@ -128,6 +148,28 @@ func StartServer(addr string, workerPool *executor.WorkerPool) {
 		writeJSON(w, http.StatusOK, resp)
 	})
 
+	mux.HandleFunc("/api/problems/submit/stream", submitStreamHandler(compilerService))
+
+	queue := service.NewSubmissionQueue(compilerService, submissionJudges, submissionQueueSize)
+	mux.HandleFunc("/api/submissions", submitAsyncHandler(queue))
+	mux.HandleFunc("/api/submissions/{id}", submissionHandler(queue))
+
+	mux.HandleFunc("/api/stress", stressHandler(compilerService))
+
+	mux.HandleFunc("/api/admin/submissions", adminHandler(historyHandler))
+	mux.HandleFunc("/api/admin/problems", adminHandler(problemsHandler(compilerService)))
+	mux.HandleFunc("/api/admin/problems/import", adminHandler(importProblemHandler(compilerService)))
+	mux.HandleFunc("/api/admin/problems/{id}", adminHandler(problemHandler(compilerService)))
+	mux.HandleFunc("/api/admin/problems/{id}/publish", adminHandler(publishHandler(compilerService)))
+	mux.HandleFunc("/api/admin/problems/{id}/validate", adminHandler(validateHandler(compilerService)))
+	mux.HandleFunc("/api/admin/problems/{id}/archive", adminHandler(archiveHandler(false)))
+	mux.HandleFunc("/api/admin/problems/{id}/restore", adminHandler(archiveHandler(true)))
+	mux.HandleFunc("/api/admin/problems/{id}/tests/{n}", adminHandler(testHandler(compilerService)))
+	mux.HandleFunc("/api/admin/problems/{id}/outputs", adminHandler(generateOutputsHandler(compilerService)))
+	mux.HandleFunc("/api/admin/problems/{id}/generate", adminHandler(generateTestsHandler(compilerService)))
+	mux.HandleFunc("/api/admin/problems/{id}/versions", adminHandler(versionsHandler))
+	mux.HandleFunc("/api/admin/problems/{id}/versions/{version}", adminHandler(versionHandler))
+
 	fileServer := http.FileServer(http.Dir("web"))
 	mux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
 		if r.URL.Path == "/" {
@@ -154,6 +196,6 @@ func writeJSON(w http.ResponseWriter, status int, payload interface{}) {
 
 func setCORSHeaders(w http.ResponseWriter) {
 	w.Header().Set("Access-Control-Allow-Origin", "*")
-	w.Header().Set("Access-Control-Allow-Methods", "POST, OPTIONS")
-	w.Header().Set("Access-Control-Allow-Headers", "Content-Type")
+	w.Header().Set("Access-Control-Allow-Methods", "GET, POST, PUT, DELETE, OPTIONS")
+	w.Header().Set("Access-Control-Allow-Headers", "Content-Type, Authorization")
 }
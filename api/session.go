@@ -0,0 +1,94 @@
+package api
+
+import (
+	"log"
+	"time"
+
+	"xcodeengine/model"
+	"xcodeengine/service"
+
+	"golang.org/x/net/websocket"
+)
+
+// sessionMessage is the JSON frame exchanged over an interactive session socket.
+//
+// The client opens with a "start" frame carrying the program, then sends "stdin"
+// frames, an optional "eof" and may "kill" the program at any time. The server
+// answers with "started", streams "stdout"/"stderr" frames and ends with "exit".
+type sessionMessage struct {
+	Type     string   `json:"type"`
+	Data     string   `json:"data,omitempty"`
+	Code     string   `json:"code,omitempty"`
+	Language string   `json:"language,omitempty"`
+	Flags    []string `json:"flags,omitempty"`
+	model.ProjectFiles
+
+	Version       string `json:"version,omitempty"`
+	ExitCode      *int   `json:"exit_code,omitempty"`
+	Error         string `json:"error,omitempty"`
+	ExecutionTime string `json:"execution_time,omitempty"`
+}
+
+// sessionHandler runs interactive programs over a WebSocket
+func sessionHandler(compilerService *service.CompilerService) websocket.Handler {
+	return func(ws *websocket.Conn) {
+		defer ws.Close()
+
+		var start sessionMessage
+		if err := websocket.JSON.Receive(ws, &start); err != nil {
+			return
+		}
+		if start.Type != "start" || start.Language == "" || (start.Code == "" && len(start.Files) == 0 && start.Archive == "") {
+			websocket.JSON.Send(ws, sessionMessage{Type: "error", Error: "first message must be a start frame with code and language"})
+			return
+		}
+
+		session, err := compilerService.StartSession(start.Code, start.Language, start.Flags, start.ProjectFiles)
+		if err != nil {
+			websocket.JSON.Send(ws, sessionMessage{Type: "error", Error: err.Error()})
+			return
+		}
+		websocket.JSON.Send(ws, sessionMessage{Type: "started", Version: session.Version})
+
+		// Client frames feed stdin until the socket closes
+		go func() {
+			for {
+				var msg sessionMessage
+				if err := websocket.JSON.Receive(ws, &msg); err != nil {
+					session.Kill()
+					return
+				}
+				switch msg.Type {
+				case "stdin":
+					if err := session.Write([]byte(msg.Data)); err != nil {
+						websocket.JSON.Send(ws, sessionMessage{Type: "error", Error: err.Error()})
+					}
+				case "eof":
+					session.CloseStdin()
+				case "kill":
+					session.Kill()
+				}
+			}
+		}()
+
+		// Output is drained even after the client is gone so the container is released
+		for chunk := range session.Output() {
+			if err := websocket.JSON.Send(ws, sessionMessage{Type: chunk.Stream, Data: chunk.Data}); err != nil {
+				session.Kill()
+			}
+		}
+
+		result := session.Wait()
+		exit := sessionMessage{
+			Type:          "exit",
+			ExitCode:      &result.ExitCode,
+			ExecutionTime: result.Duration.Round(time.Millisecond).String(),
+		}
+		if result.Error != nil {
+			exit.Error = result.Error.Error()
+		}
+		if err := websocket.JSON.Send(ws, exit); err != nil {
+			log.Printf("failed to send session result: %v", err)
+		}
+	}
+}
@@ -0,0 +1,154 @@
+package api
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+
+	"xcodeengine/executor"
+	"xcodeengine/model"
+	"xcodeengine/service"
+)
+
+// sseWriter writes Server-Sent Events to a response, flushing after each event
+type sseWriter struct {
+	w       http.ResponseWriter
+	flusher http.Flusher
+	mu      sync.Mutex
+}
+
+// newSSEWriter starts an event stream; it fails if the connection cannot be flushed
+func newSSEWriter(w http.ResponseWriter) (*sseWriter, bool) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		return nil, false
+	}
+	setCORSHeaders(w)
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.Header().Set("X-Accel-Buffering", "no")
+	w.WriteHeader(http.StatusOK)
+	flusher.Flush()
+	return &sseWriter{w: w, flusher: flusher}, true
+}
+
+// send writes one event with a JSON payload
+func (s *sseWriter) send(event string, payload interface{}) {
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	fmt.Fprintf(s.w, "event: %s\ndata: %s\n\n", event, data)
+	s.flusher.Flush()
+}
+
+// executeStreamHandler runs code like /api/execute and streams its output as
+// "output" events, followed by a final "result" or "error" event
+func executeStreamHandler(compilerService *service.CompilerService) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		setCORSHeaders(w)
+		if r.Method == http.MethodOptions {
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+		if r.Method != http.MethodPost {
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+
+		var req ExecuteRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, "invalid request body", http.StatusBadRequest)
+			return
+		}
+		req.Language = strings.TrimSpace(req.Language)
+		req.Code = strings.TrimSpace(req.Code)
+
+		hasProject := len(req.Files) > 0 || req.Archive != ""
+		if req.Language == "" || (req.Code == "" && !hasProject) {
+			http.Error(w, "code (or files) and language are required", http.StatusBadRequest)
+			return
+		}
+
+		events, ok := newSSEWriter(w)
+		if !ok {
+			http.Error(w, "streaming unsupported", http.StatusInternalServerError)
+			return
+		}
+
+		onOutput := func(chunk executor.OutputChunk) {
+			events.send("output", sessionMessage{Type: chunk.Stream, Data: chunk.Data})
+		}
+
+		var (
+			resp *service.CompileResult
+			err  error
+		)
+		if strings.EqualFold(req.Mode, "problem") {
+			resp, err = compilerService.ExecuteProblemCodeStream(req.Code, req.Language, req.Flags, req.ProjectFiles, onOutput)
+		} else {
+			encoded := base64.StdEncoding.EncodeToString([]byte(req.Code))
+			resp, err = compilerService.CompileStream(encoded, req.Language, req.Input, req.Flags, req.ProjectFiles, onOutput)
+		}
+		if err != nil {
+			events.send("error", sessionMessage{Type: "error", Error: err.Error()})
+			return
+		}
+
+		events.send("result", ExecuteResponse{
+			Output:        resp.Output,
+			Error:         resp.Error,
+			StatusMessage: resp.StatusMessage,
+			Success:       resp.Success,
+			ExecutionTime: resp.ExecutionTime,
+			Version:       resp.Version,
+		})
+	}
+}
+
+// submitStreamHandler judges a submission like /api/problems/submit and streams
+// "progress" events for each test, followed by a final "result" or "error" event
+func submitStreamHandler(compilerService *service.CompilerService) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		setCORSHeaders(w)
+		if r.Method == http.MethodOptions {
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+		if r.Method != http.MethodPost {
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+
+		var req model.ProblemSubmissionRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, "invalid request body", http.StatusBadRequest)
+			return
+		}
+		if req.ProblemID == "" {
+			http.Error(w, "problem_id is required", http.StatusBadRequest)
+			return
+		}
+
+		events, ok := newSSEWriter(w)
+		if !ok {
+			http.Error(w, "streaming unsupported", http.StatusInternalServerError)
+			return
+		}
+
+		resp, err := compilerService.JudgeSubmission(req, func(event model.JudgeEvent) {
+			events.send("progress", event)
+		})
+		if err != nil {
+			events.send("error", sessionMessage{Type: "error", Error: err.Error()})
+			return
+		}
+		events.send("result", resp)
+	}
+}
@@ -0,0 +1,138 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+
+	"xcodeengine/model"
+	"xcodeengine/service"
+	"xcodeengine/submissions"
+)
+
+// Judges and queue capacity of asynchronous submissions
+var (
+	submissionJudges    = 2
+	submissionQueueSize = 100
+)
+
+// SetSubmissionQueue sizes the asynchronous submission queue; call it before StartServer
+func SetSubmissionQueue(judges, capacity int) {
+	submissionJudges, submissionQueueSize = judges, capacity
+}
+
+// submitAsyncHandler queues a submission and answers at once with its ID
+func submitAsyncHandler(queue *service.SubmissionQueue) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		setCORSHeaders(w)
+		if r.Method == http.MethodOptions {
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+		if r.Method != http.MethodPost {
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+
+		var req model.ProblemSubmissionRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, "invalid request body", http.StatusBadRequest)
+			return
+		}
+		if req.ProblemID == "" {
+			http.Error(w, "problem_id is required", http.StatusBadRequest)
+			return
+		}
+
+		submission, err := queue.Submit(req)
+		switch {
+		case errors.Is(err, service.ErrQueueFull):
+			w.Header().Set("Retry-After", "5")
+			http.Error(w, err.Error(), http.StatusServiceUnavailable)
+			return
+		case errors.Is(err, service.ErrProblemNotFound):
+			http.Error(w, err.Error(), http.StatusNotFound)
+			return
+		case err != nil:
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		w.Header().Set("Location", "/api/submissions/"+submission.ID)
+		writeJSON(w, http.StatusAccepted, submission)
+	}
+}
+
+// submissionHandler reports the state of a queued, running or finished submission
+func submissionHandler(queue *service.SubmissionQueue) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		setCORSHeaders(w)
+		if r.Method == http.MethodOptions {
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+		if r.Method != http.MethodGet {
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+
+		submission, err := queue.Get(r.PathValue("id"))
+		if errors.Is(err, service.ErrSubmissionNotFound) {
+			http.Error(w, err.Error(), http.StatusNotFound)
+			return
+		}
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		writeJSON(w, http.StatusOK, submission)
+	}
+}
+
+// historyHandler lists recorded submissions, newest first, by submitter (the "user"
+// parameter) or by problem. "limit" sizes the page and "before" continues from the
+// "next" of the previous page.
+func historyHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	store := submissions.Active()
+	if store == nil {
+		http.Error(w, "no submission store is configured", http.StatusServiceUnavailable)
+		return
+	}
+
+	params := r.URL.Query()
+	user, problemID := params.Get("user"), params.Get("problem")
+	if user == "" && problemID == "" {
+		http.Error(w, "user or problem is required", http.StatusBadRequest)
+		return
+	}
+	query := submissions.Query{Before: params.Get("before")}
+	if limit := params.Get("limit"); limit != "" {
+		n, err := strconv.Atoi(limit)
+		if err != nil || n < 1 {
+			http.Error(w, "invalid limit", http.StatusBadRequest)
+			return
+		}
+		query.Limit = n
+	}
+
+	var history *model.SubmissionHistory
+	var err error
+	if user != "" {
+		history, err = store.BySubmitter(user, problemID, query)
+	} else {
+		history, err = store.ByProblem(problemID, query)
+	}
+	if errors.Is(err, submissions.ErrNotFound) {
+		http.Error(w, "unknown before submission", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	writeJSON(w, http.StatusOK, history)
+}
@@ -0,0 +1,93 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"xcodeengine/config"
+	"xcodeengine/problems"
+)
+
+// runImport implements `engine import [flags] PACKAGE`, which converts a Polygon or
+// Kattis package (a zip archive or an unpacked folder) into a problem folder
+func runImport(args []string) int {
+	cmd := flag.NewFlagSet("import", flag.ContinueOnError)
+	format := cmd.String("format", "", "package format: polygon or kattis (detected when empty)")
+	id := cmd.String("id", "", "problem ID (defaults to the package's name)")
+	dir := cmd.String("dir", "", "problems directory to write to (defaults to PROBLEMS_DIR)")
+	force := cmd.Bool("force", false, "replace an existing problem with the same ID")
+	publish := cmd.Bool("publish", false, "publish the problem once the engine's validator accepts its inputs, instead of importing a draft")
+	cmd.Usage = func() {
+		fmt.Fprintln(cmd.Output(), "usage: engine import [flags] PACKAGE")
+		cmd.PrintDefaults()
+	}
+	if err := cmd.Parse(args); err != nil {
+		return 2
+	}
+	if cmd.NArg() != 1 {
+		cmd.Usage()
+		return 2
+	}
+	if *dir == "" {
+		*dir = config.LoadConfig().ProblemsDir
+	}
+	if *dir == "" {
+		fmt.Fprintln(os.Stderr, "import: no problems directory; set -dir or PROBLEMS_DIR")
+		return 2
+	}
+
+	pkg, name, err := openPackage(cmd.Arg(0))
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "import: %v\n", err)
+		return 1
+	}
+	problem, warnings, err := problems.Import(pkg, name, *format, *id)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "import: %v\n", err)
+		return 1
+	}
+	for _, warning := range warnings {
+		fmt.Fprintf(os.Stderr, "warning: %s\n", warning)
+	}
+	// Inputs are validated in the sandbox, which this command has no access to: a draft
+	// when it is published through the admin API, and a published problem by the
+	// engine, which holds it back until its validator accepts the inputs
+	problem.Draft = !*publish
+	if err := problems.WriteFolder(*dir, problem, *force); err != nil {
+		fmt.Fprintf(os.Stderr, "import: %v\n", err)
+		return 1
+	}
+	state := "a draft"
+	if *publish {
+		state = "published, pending validation"
+	}
+	fmt.Printf("Imported %s (%d tests) into %s as %s\n", problem.ID, len(problem.TestCases), filepath.Join(*dir, problem.ID), state)
+	return 0
+}
+
+// openPackage opens a package from a zip archive or a folder and returns it with its name
+func openPackage(path string) (fs.FS, string, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, "", err
+	}
+	if info.IsDir() {
+		return os.DirFS(path), filepath.Base(filepath.Clean(path)), nil
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, "", err
+	}
+	pkg, root, err := problems.OpenPackage(data)
+	if err != nil {
+		return nil, "", err
+	}
+	if root == "" {
+		root = strings.TrimSuffix(filepath.Base(path), filepath.Ext(path))
+	}
+	return pkg, root, nil
+}
@@ -7,11 +7,16 @@ import (
 	"xcodeengine/config"
 	"xcodeengine/executor"
 	"xcodeengine/natshandler"
+	"xcodeengine/problems"
+	"xcodeengine/service"
+	"xcodeengine/submissions"
 
 	"log"
 	"net/http"
 	_ "net/http/pprof"
+	"os"
 	"strings"
+	"time"
 
 	"github.com/nats-io/nats.go"
 	"go.uber.org/zap"
@@ -20,6 +25,14 @@ import (
 )
 
 func main() {
+	if len(os.Args) > 1 {
+		switch os.Args[1] {
+		case "import":
+			os.Exit(runImport(os.Args[2:]))
+		case "stress":
+			os.Exit(runStress(os.Args[2:]))
+		}
+	}
 
 	// Load configuration
 	log.Println("Loading engine configuration...")
@@ -49,22 +62,72 @@ func main() {
 
 	log.Println("Prepping Code Execution engine")
 
-	// Check if the worker image exists
-	imageName := "24321010/worker"
-	log.Printf("Checking if Docker image '%s' exists locally...", imageName)
-	if !checkIfDockerImageExists(imageName) {
-		logger.Fatal("Worker Docker image not found. Exiting...",
-			zap.String("image", imageName))
+	// Apply admin-defined flag allowlists
+	for language, flags := range config.FlagAllowlists {
+		if err := executor.SetFlagAllowlist(language, flags); err != nil {
+			logger.Fatal("Invalid flag allowlist",
+				zap.String("language", language),
+				zap.Error(err))
+		}
+	}
+
+	// Container pools: one generic pool for quick runs and a fatter one for the JVM, plus
+	// one pool per language version that needs its own toolchain (python@3.8, java@21)
+	pools := []executor.PoolConfig{
+		{Name: executor.DefaultPoolName, Image: "24321010/worker", Size: 2, MemoryLimit: 400, CPUNanoLimit: 500, Languages: []string{"go", "js", "python", "cpp", "c"}},
+		{Name: "jvm", Image: "24321010/worker", Size: 1, MemoryLimit: 800, CPUNanoLimit: 1000, Languages: []string{"java"}},
+		{Name: "python38", Image: "24321010/worker-python38", Size: 1, MemoryLimit: 400, CPUNanoLimit: 500},
+		{Name: "jvm21", Image: "24321010/worker", Size: 1, MemoryLimit: 800, CPUNanoLimit: 1000},
+	}
+
+	// Check if the worker images exist
+	for _, pool := range pools {
+		log.Printf("Checking if Docker image '%s' for pool '%s' exists locally...", pool.Image, pool.Name)
+		if !checkIfDockerImageExists(pool.Image) {
+			logger.Fatal("Worker Docker image not found. Exiting...",
+				zap.String("pool", pool.Name),
+				zap.String("image", pool.Image))
+		}
+		log.Printf("Docker image '%s' found.", pool.Image)
 	}
-	log.Printf("Docker image '%s' found.", imageName)
 
 	log.Println("Starting worker pool initialization")
-	workerPool, err := executor.NewWorkerPool(2, 3, 400, 500, logStreamer) //workers, jobs, memory, vcpu,logstreamer
+	workerPool, err := executor.NewWorkerPool(pools, 3, logStreamer) //pools, jobs per pool, logstreamer
 	if err != nil {
 		logger.Fatal("Failed to initialize worker pool",
 			zap.Error(err))
 	}
 	log.Println("Worker pool initialized successfully")
+	workerPool.SetSessionLimits(executor.SessionLimits{
+		MaxDuration: time.Duration(config.SessionMaxSeconds) * time.Second,
+		IdleTimeout: time.Duration(config.SessionIdleSeconds) * time.Second,
+		MaxPerPool:  config.SessionMaxPerPool,
+	})
+	workerPool.SetJudgeParallelism(config.JudgeParallelism)
+
+	if config.ProblemsDir != "" {
+		store, err := problems.LoadStore(config.ProblemsDir)
+		if err != nil {
+			logger.Fatal("Failed to load problems",
+				zap.String("dir", config.ProblemsDir),
+				zap.Error(err))
+		}
+		problems.Use(store)
+		// Folders published by hand go live once their validator accepts their inputs
+		store.OnReload(service.NewCompilerService(workerPool).ValidatePending)
+		go store.Watch(time.Duration(config.ProblemsReloadSeconds)*time.Second, nil)
+	}
+
+	if config.SubmissionsDB != "" {
+		store, err := submissions.Open(config.SubmissionsDB)
+		if err != nil {
+			logger.Fatal("Failed to open submission store",
+				zap.String("path", config.SubmissionsDB),
+				zap.Error(err))
+		}
+		defer store.Close()
+		submissions.Use(store)
+	}
 
 	// Connect to NATS
 	log.Printf("Connecting to NATS at: %s", config.NatsURL)
@@ -106,6 +169,8 @@ func main() {
 	log.Println("Engine service is up and listening for requests")
 
 	// Launch the HTTP API + UI server.
+	api.SetAdminToken(config.AdminToken)
+	api.SetSubmissionQueue(config.SubmissionJudges, config.SubmissionQueueSize)
 	api.StartServer(":"+config.Port, workerPool)
 
 	// Keep the service running
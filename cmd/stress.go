@@ -0,0 +1,103 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+
+	"xcodeengine/config"
+	"xcodeengine/model"
+	"xcodeengine/problems"
+)
+
+// runStress implements `engine stress [flags] SOLUTION BRUTE GENERATOR [ARGS...]`,
+// which asks a running engine to compare a solution with a brute force on generated
+// inputs. It exits 1 when it finds an input where they differ.
+func runStress(args []string) int {
+	cmd := flag.NewFlagSet("stress", flag.ContinueOnError)
+	server := cmd.String("server", "", "engine URL (defaults to http://localhost:HTTP_PORT)")
+	seed := cmd.Int64("seed", 1, "first seed passed to the generator")
+	runs := cmd.Int("runs", 0, "seeds to try (defaults to the engine's default)")
+	timeLimit := cmd.Int("time-limit", 0, "time limit per run in milliseconds")
+	comparator := cmd.String("comparator", "", "output comparison: exact, tokens, float, ... (defaults to exact)")
+	cmd.Usage = func() {
+		fmt.Fprintln(cmd.Output(), "usage: engine stress [flags] SOLUTION BRUTE GENERATOR [ARGS...]")
+		fmt.Fprintln(cmd.Output(), "Languages come from the file extensions; the seed is passed after ARGS.")
+		cmd.PrintDefaults()
+	}
+	if err := cmd.Parse(args); err != nil {
+		return 2
+	}
+	if cmd.NArg() < 3 {
+		cmd.Usage()
+		return 2
+	}
+	if *server == "" {
+		*server = "http://localhost:" + config.LoadConfig().Port
+	}
+
+	req := model.StressRequest{
+		Args:        cmd.Args()[3:],
+		StartSeed:   *seed,
+		MaxRuns:     *runs,
+		TimeLimitMs: *timeLimit,
+		Comparator:  model.Comparator{Mode: *comparator},
+	}
+	for i, program := range []*model.StressProgram{&req.Solution, &req.BruteForce, &req.Generator} {
+		loaded, err := readProgram(cmd.Arg(i))
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "stress: %v\n", err)
+			return 2
+		}
+		*program = loaded
+	}
+
+	body, err := json.Marshal(req)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "stress: %v\n", err)
+		return 1
+	}
+	httpResp, err := http.Post(strings.TrimSuffix(*server, "/")+"/api/stress", "application/json", bytes.NewReader(body))
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "stress: %v\n", err)
+		return 1
+	}
+	defer httpResp.Body.Close()
+	if httpResp.StatusCode != http.StatusOK {
+		message, _ := io.ReadAll(httpResp.Body)
+		fmt.Fprintf(os.Stderr, "stress: %s\n", strings.TrimSpace(string(message)))
+		return 1
+	}
+	var resp model.StressResponse
+	if err := json.NewDecoder(httpResp.Body).Decode(&resp); err != nil {
+		fmt.Fprintf(os.Stderr, "stress: invalid response: %v\n", err)
+		return 1
+	}
+
+	if !resp.Found {
+		fmt.Printf("No difference in %d runs\n", resp.Runs)
+		return 0
+	}
+	fmt.Printf("%s with seed %d (run %d)\n", resp.Status, resp.Seed, resp.Runs)
+	fmt.Printf("--- input\n%s\n--- solution\n%s\n--- brute force\n%s\n",
+		strings.TrimRight(resp.Input, "\n"), strings.TrimRight(resp.Output, "\n"), strings.TrimRight(resp.Expected, "\n"))
+	return 1
+}
+
+// readProgram reads a source file as a stress test program
+func readProgram(path string) (model.StressProgram, error) {
+	language, err := problems.SourceLanguage(path)
+	if err != nil {
+		return model.StressProgram{}, err
+	}
+	code, err := os.ReadFile(path)
+	if err != nil {
+		return model.StressProgram{}, err
+	}
+	return model.StressProgram{Language: language, Code: string(code)}, nil
+}
@@ -4,6 +4,7 @@ import (
 	"log"
 	"os"
 	"strconv"
+	"strings"
 
 	"github.com/joho/godotenv"
 )
@@ -21,6 +22,34 @@ type Config struct {
 
 	BetterStackUploadURL   string
 	BetterStackSourceToken string
+
+	// Interactive session limits, in seconds, and how many sessions one pool may host
+	SessionMaxSeconds  int
+	SessionIdleSeconds int
+	SessionMaxPerPool  int
+
+	// JudgeParallelism caps how many tests of one submission run at once
+	JudgeParallelism int
+
+	// SubmissionJudges is how many queued submissions are judged at once, and
+	// SubmissionQueueSize how many may wait before new ones are refused
+	SubmissionJudges    int
+	SubmissionQueueSize int
+
+	// SubmissionsDB is the file judged submissions are recorded in; empty keeps none
+	SubmissionsDB string
+
+	// ProblemsDir, when set, is loaded instead of the built-in problems and
+	// reloaded every ProblemsReloadSeconds when its files change
+	ProblemsDir           string
+	ProblemsReloadSeconds int
+
+	// AdminToken is the bearer token for the admin API, which is off while it is empty
+	AdminToken string
+
+	// FlagAllowlists overrides the per-language flag allowlist, read from
+	// FLAG_ALLOWLIST_<LANGUAGE>=-O2,-Wall,run:-ea
+	FlagAllowlists map[string][]string
 }
 
 func LoadConfig() Config {
@@ -36,6 +65,24 @@ func LoadConfig() Config {
 
 		BetterStackUploadURL:   getEnv("BETTERSTACKUPLOADURL", ""),
 		BetterStackSourceToken: getEnv("BETTERSTACKSOURCETOKEN", ""),
+
+		SessionMaxSeconds:  getEnvInt("SESSION_MAX_SECONDS", 120),
+		SessionIdleSeconds: getEnvInt("SESSION_IDLE_SECONDS", 30),
+		SessionMaxPerPool:  getEnvInt("SESSION_MAX_PER_POOL", 1),
+
+		JudgeParallelism: getEnvInt("JUDGE_PARALLELISM", 4),
+
+		SubmissionJudges:    getEnvInt("SUBMISSION_JUDGES", 2),
+		SubmissionQueueSize: getEnvInt("SUBMISSION_QUEUE_SIZE", 100),
+
+		SubmissionsDB: getEnv("SUBMISSIONS_DB", "submissions.db"),
+
+		ProblemsDir:           getEnv("PROBLEMS_DIR", ""),
+		ProblemsReloadSeconds: getEnvInt("PROBLEMS_RELOAD_SECONDS", 5),
+
+		AdminToken: getEnv("ADMIN_TOKEN", ""),
+
+		FlagAllowlists: getEnvLists("FLAG_ALLOWLIST_"),
 	}
 }
 
@@ -46,6 +93,27 @@ func getEnv(key, defaultValue string) string {
 	return defaultValue
 }
 
+// getEnvLists collects comma-separated lists from every variable with the given
+// prefix, keyed by the lowercased remainder of the variable name
+func getEnvLists(prefix string) map[string][]string {
+	lists := make(map[string][]string)
+	for _, kv := range os.Environ() {
+		key, value, _ := strings.Cut(kv, "=")
+		name, ok := strings.CutPrefix(key, prefix)
+		if !ok || name == "" {
+			continue
+		}
+		var items []string
+		for _, item := range strings.Split(value, ",") {
+			if item = strings.TrimSpace(item); item != "" {
+				items = append(items, item)
+			}
+		}
+		lists[strings.ToLower(name)] = items
+	}
+	return lists
+}
+
 func getEnvInt(key string, defaultValue int) int {
 	if value, exists := os.LookupEnv(key); exists {
 		if intVal, err := strconv.Atoi(value); err == nil {
@@ -2,87 +2,439 @@ package executor
 
 import (
 	"fmt"
+	"path"
+	"regexp"
+	"sort"
 	"strings"
 	"time"
 )
 
+// LanguageVersion describes one toolchain version of a language
+type LanguageVersion struct {
+	Name      string   // version identifier, e.g. "gnu++20" or "3.12"
+	Toolchain string   // compiler or interpreter binary used for this version
+	Flags     []string // fixed toolchain flags that select the version, e.g. -std=gnu++20
+	Runtime   string   // virtual machine that runs the build output, for languages that have one
+	Pool      string   // container pool with the image for this version; empty uses the language pool
+}
+
+// BuildSpec carries everything a language needs to build and run a prepared workspace
+type BuildSpec struct {
+	Version      LanguageVersion
+	CompileFlags []string // allowlisted flags passed to the compiler
+	RunFlags     []string // allowlisted flags passed to the runtime
+	Workdir      string   // absolute workspace path; sources live in Workdir/src
+	Entrypoint   string   // entry file relative to Workdir/src
+	EntryClass   string   // fully qualified main class, for JVM languages
+	Args         []string // program arguments, appended after the run command
+}
+
+// FlagStage tells whether an allowlisted flag goes to the compiler or the runtime
+type FlagStage string
+
+const (
+	StageCompile FlagStage = "compile"
+	StageRun     FlagStage = "run"
+)
+
 // LanguageConfig defines execution settings for a language
 type LanguageConfig struct {
-	Timeout time.Duration
-	Args    func(code, stdin string) []string
+	Timeout        time.Duration
+	Pool           string // container pool that runs this language unless a pool claims it; empty routes to DefaultPoolName
+	DefaultVersion string
+	Versions       map[string]LanguageVersion
+	AllowedFlags   map[string]FlagStage        // flags a request may pick, keyed by the exact flag
+	SourceFile     string                      // file a single-file submission is written to
+	UsesEntrypoint bool                        // multi-file projects must name the file Run starts from
+	Build          func(spec BuildSpec) string // compile step run inside Workdir/src; nil for interpreted languages
+	Run            func(spec BuildSpec) string // command that starts the program; stdin is attached by the caller
+}
+
+// gccFlags is the default allowlist shared by the C and C++ compilers
+var gccFlags = map[string]FlagStage{
+	"-O0":             StageCompile,
+	"-O1":             StageCompile,
+	"-O2":             StageCompile,
+	"-O3":             StageCompile,
+	"-Wall":           StageCompile,
+	"-Wextra":         StageCompile,
+	"-Wpedantic":      StageCompile,
+	"-Werror":         StageCompile,
+	"-g":              StageCompile,
+	"-lm":             StageCompile,
+	"-DONLINE_JUDGE":  StageCompile,
+	"-fno-exceptions": StageCompile,
+}
+
+// safeFlag restricts allowlisted flags to characters that need no shell quoting
+var safeFlag = regexp.MustCompile(`^-[A-Za-z0-9_=+:.,-]+$`)
+
+// runExecutable starts the binary produced by a compiled language's build step
+func runExecutable(spec BuildSpec) string {
+	return spec.Workdir + "/exe"
 }
 
 // languageConfigs holds execution configurations for supported languages
 var languageConfigs = map[string]LanguageConfig{
 	"go": {
-		Timeout: 10 * time.Second,
-		Args: func(code, stdin string) []string {
-			return []string{"sh", "-c", fmt.Sprintf(
-				`printf '%%s' '%s' > /app/temp/input.txt && echo '%s' > /app/temp/code.go && go run /app/temp/code.go < /app/temp/input.txt`,
-				sanitizePrintf(stdin),
-				strings.ReplaceAll(code, "'", "'\\''"),
-			)}
+		Timeout:        10 * time.Second,
+		DefaultVersion: "1.22",
+		Versions: map[string]LanguageVersion{
+			"1.22": {Toolchain: "go"},
+		},
+		AllowedFlags: map[string]FlagStage{
+			"-gcflags=-B": StageCompile,
+		},
+		SourceFile: "code.go",
+		Build: func(spec BuildSpec) string {
+			// Projects with a go.mod build as a module, loose files as a file list
+			return fmt.Sprintf(`if [ -f go.mod ]; then %[1]s build%[2]s -o %[3]s/exe .; else %[1]s build%[2]s -o %[3]s/exe *.go; fi`,
+				spec.Version.command(), joinFlags(spec.CompileFlags), spec.Workdir)
 		},
+		Run: runExecutable,
 	},
 	"js": {
-		Timeout: 10 * time.Second,
-		Args: func(code, stdin string) []string {
-			return []string{"sh", "-c", fmt.Sprintf(
-				`printf '%%s' '%s' > /app/temp/input.txt && echo '%s' > /app/temp/code.js && node /app/temp/code.js < /app/temp/input.txt`,
-				sanitizePrintf(stdin),
-				strings.ReplaceAll(code, "'", "'\\''"),
-			)}
+		Timeout:        10 * time.Second,
+		DefaultVersion: "20",
+		Versions: map[string]LanguageVersion{
+			"20": {Toolchain: "node"},
+		},
+		AllowedFlags: map[string]FlagStage{
+			"--use-strict":             StageRun,
+			"--stack-size=65500":       StageRun,
+			"--max-old-space-size=256": StageRun,
+		},
+		SourceFile:     "code.js",
+		UsesEntrypoint: true,
+		Run: func(spec BuildSpec) string {
+			return fmt.Sprintf(`%s%s %s/src/%s`, spec.Version.command(), joinFlags(spec.RunFlags), spec.Workdir, spec.Entrypoint)
 		},
 	},
 	"python": {
-		Timeout: 10 * time.Second,
-		Args: func(code, stdin string) []string {
-			return []string{"sh", "-c", fmt.Sprintf(
-				`printf '%%s' '%s' > /app/temp/input.txt && echo '%s' > /app/temp/code.py && python3 /app/temp/code.py < /app/temp/input.txt`,
-				sanitizePrintf(stdin),
-				strings.ReplaceAll(code, "'", "'\\''"),
-			)}
+		Timeout:        10 * time.Second,
+		DefaultVersion: "3.12",
+		Versions: map[string]LanguageVersion{
+			"3.8":  {Toolchain: "python3.8", Pool: "python38"},
+			"3.12": {Toolchain: "python3"},
+		},
+		AllowedFlags: map[string]FlagStage{
+			"-O": StageRun,
+			"-B": StageRun,
+		},
+		SourceFile:     "code.py",
+		UsesEntrypoint: true,
+		Run: func(spec BuildSpec) string {
+			return fmt.Sprintf(`%s%s %s/src/%s`, spec.Version.command(), joinFlags(spec.RunFlags), spec.Workdir, spec.Entrypoint)
 		},
 	},
 	"cpp": {
-		Timeout: 10 * time.Second,
-		Args: func(code, stdin string) []string {
-			return []string{"sh", "-c", fmt.Sprintf(
-				`printf '%%s' '%s' > /app/temp/input.txt && echo '%s' > /app/temp/code.cpp && g++ -o /app/temp/exe /app/temp/code.cpp && /app/temp/exe < /app/temp/input.txt`,
-				sanitizePrintf(stdin),
-				strings.ReplaceAll(code, "'", "'\\''"),
-			)}
+		Timeout:        10 * time.Second,
+		DefaultVersion: "gnu++17",
+		Versions: map[string]LanguageVersion{
+			"gnu++14": {Toolchain: "g++", Flags: []string{"-std=gnu++14"}},
+			"gnu++17": {Toolchain: "g++", Flags: []string{"-std=gnu++17"}},
+			"gnu++20": {Toolchain: "g++", Flags: []string{"-std=gnu++20"}},
 		},
+		AllowedFlags: gccFlags,
+		SourceFile:   "code.cpp",
+		Build: func(spec BuildSpec) string {
+			return fmt.Sprintf(`%s%s -I. -o %s/exe $(find . -name '*.cpp' -o -name '*.cc' -o -name '*.cxx')`,
+				spec.Version.command(), joinFlags(spec.CompileFlags), spec.Workdir)
+		},
+		Run: runExecutable,
 	},
 	"c": {
-		Timeout: 10 * time.Second,
-		Args: func(code, stdin string) []string {
-			return []string{"sh", "-c", fmt.Sprintf(
-				`printf '%%s' '%s' > /app/temp/input.txt && echo '%s' > /app/temp/code.c && gcc -o /app/temp/exe /app/temp/code.c && /app/temp/exe < /app/temp/input.txt`,
-				sanitizePrintf(stdin),
-				strings.ReplaceAll(code, "'", "'\\''"),
-			)}
+		Timeout:        10 * time.Second,
+		DefaultVersion: "c17",
+		Versions: map[string]LanguageVersion{
+			"c99": {Toolchain: "gcc", Flags: []string{"-std=c99"}},
+			"c11": {Toolchain: "gcc", Flags: []string{"-std=c11"}},
+			"c17": {Toolchain: "gcc", Flags: []string{"-std=c17"}},
+		},
+		AllowedFlags: gccFlags,
+		SourceFile:   "code.c",
+		Build: func(spec BuildSpec) string {
+			return fmt.Sprintf(`%s -I. -o %s/exe $(find . -name '*.c')%s`,
+				spec.Version.command(), spec.Workdir, joinFlags(spec.CompileFlags))
 		},
+		Run: runExecutable,
 	},
 	"java": {
-		Timeout: 10 * time.Second,
-		Args: func(code, stdin string) []string {
-			return []string{"sh", "-c", fmt.Sprintf(
-				`printf '%%s' '%s' > /app/temp/input.txt && echo '%s' > /app/temp/Main.java && javac /app/temp/Main.java && java -cp /app/temp Main < /app/temp/input.txt`,
-				sanitizePrintf(stdin),
-				strings.ReplaceAll(code, "'", "'\\''"),
-			)}
+		Timeout:        10 * time.Second,
+		Pool:           "jvm",
+		DefaultVersion: "17",
+		Versions: map[string]LanguageVersion{
+			"11": {Toolchain: "javac", Runtime: "java", Flags: []string{"--release", "11"}},
+			"17": {Toolchain: "javac", Runtime: "java"},
+			"21": {Toolchain: "/usr/lib/jvm/java-21-openjdk/bin/javac", Runtime: "/usr/lib/jvm/java-21-openjdk/bin/java", Pool: "jvm21"},
+		},
+		AllowedFlags: map[string]FlagStage{
+			"-Xlint":           StageCompile,
+			"-Xlint:all":       StageCompile,
+			"-ea":              StageRun,
+			"-Xss64m":          StageRun,
+			"-XX:+UseSerialGC": StageRun,
+		},
+		SourceFile:     "Main.java",
+		UsesEntrypoint: true,
+		Build: func(spec BuildSpec) string {
+			return fmt.Sprintf(`%s%s -d %s/classes $(find . -name '*.java')`,
+				spec.Version.command(), joinFlags(spec.CompileFlags), spec.Workdir)
+		},
+		Run: func(spec BuildSpec) string {
+			return fmt.Sprintf(`%s%s -cp %s/classes %s`, spec.Version.Runtime, joinFlags(spec.RunFlags), spec.Workdir, spec.EntryClass)
 		},
 	},
 }
 
+// BuildArgs returns the command that builds a prepared workspace, or nil for languages without a build step
+func (c LanguageConfig) BuildArgs(spec BuildSpec) []string {
+	if c.Build == nil {
+		return nil
+	}
+	return []string{"sh", "-c", "cd " + spec.Workdir + "/src && " + c.Build(spec)}
+}
+
+// RunArgs returns the command that runs a built workspace on its stdin file. When the
+// image has a time utility, the peak memory and CPU time of the run are written to the workspace.
+func (c LanguageConfig) RunArgs(spec BuildSpec) []string {
+	measure := fmt.Sprintf(`T=; [ -x %[1]s ] && T="%[1]s -f %%M,%%U,%%S -o %[2]s/%[3]s"; `, timeUtility, spec.Workdir, rusageFile)
+	return []string{"sh", "-c", measure + "cd " + spec.Workdir + "/src && $T " + c.Run(spec) + joinFlags(spec.Args) + " < " + spec.Workdir + "/" + inputFile}
+}
+
+// timeUtility measures the peak memory and CPU time of a run; busybox provides it in the worker image
+const timeUtility = "/usr/bin/time"
+
+// rusageFile receives the peak memory of a run in KB and its user and system seconds,
+// comma separated, at the root of a workspace
+const rusageFile = "rusage.txt"
+
+// InteractiveRunArgs returns the command that runs a built workspace with stdin left attached to the caller
+func (c LanguageConfig) InteractiveRunArgs(spec BuildSpec) []string {
+	return []string{"sh", "-c", c.runScript(spec)}
+}
+
+// InteractiveArgs returns the command that builds a prepared workspace and runs it
+// with stdin left attached to the caller
+func (c LanguageConfig) InteractiveArgs(spec BuildSpec) []string {
+	script := "cd " + spec.Workdir + "/src"
+	if c.Build != nil {
+		script += " && " + c.Build(spec)
+	}
+	return []string{"sh", "-c", script + " && " + c.Run(spec) + joinFlags(spec.Args)}
+}
+
+// runScript runs the program from inside the workspace sources.
+// Every process it starts mentions the workspace path, so it can be killed by pattern.
+func (c LanguageConfig) runScript(spec BuildSpec) string {
+	return "cd " + spec.Workdir + "/src && " + c.Run(spec) + joinFlags(spec.Args)
+}
+
+// LanguageInfo lists the versions of a language that the configured pools can run
+type LanguageInfo struct {
+	Language       string   `json:"language"`
+	DefaultVersion string   `json:"default_version"`
+	Versions       []string `json:"versions"`
+}
+
+// NormalizeLanguage maps the spellings and aliases callers use for a language, such as
+// "c++" or "py", to its engine name. An alias may imply a version, as in "java@17".
+func NormalizeLanguage(lang string) string {
+
+	lang = strings.ToLower(lang)
+
+	languageMap := map[string]string{
+
+		"js":          "js",
+		"jscript":     "js",
+		"javscript":   "js",
+		"javsscript":  "js",
+		"javascipt":   "js",
+		"javasript":   "js",
+		"javascript":  "js",
+		"java script": "js",
+		"jscipt":      "js",
+
+		"python":  "python",
+		"pyt":     "python",
+		"pyn":     "python",
+		"pythn":   "python",
+		"phyton":  "python",
+		"py":      "python",
+		"py thon": "python",
+		"pthon":   "python",
+
+		"go":      "go",
+		"golang":  "go",
+		"gol":     "go",
+		"goo":     "go",
+		"g o":     "go",
+		"golangg": "go",
+
+		"cpp":    "cpp",
+		"c++":    "cpp",
+		"cp":     "cpp",
+		"cppp":   "cpp",
+		"c plus": "cpp",
+		"cxx":    "cpp",
+		"cc":     "cpp",
+		"cpp ":   "cpp",
+
+		"c":     "c",
+		" c":    "c",
+		"c ":    "c",
+		"clang": "c",
+
+		"java":   "java",
+		"jav":    "java",
+		"jvaa":   "java",
+		"java11": "java@11",
+		"java17": "java@17",
+		"java21": "java@21",
+	}
+
+	if normalized, ok := languageMap[lang]; ok {
+		return normalized
+	}
+
+	return lang
+}
+
 // GetLanguageConfig retrieves the configuration for a given language
 func GetLanguageConfig(language string) (LanguageConfig, bool) {
 	config, ok := languageConfigs[language]
 	return config, ok
 }
 
-func sanitizePrintf(input string) string {
-	escaped := strings.ReplaceAll(input, "%", "%%")
-	return strings.ReplaceAll(escaped, "'", "'\\''")
+// ResolveVersion returns the requested version of a language, or its default when version is empty
+func ResolveVersion(language, version string) (LanguageVersion, error) {
+	config, ok := languageConfigs[language]
+	if !ok {
+		return LanguageVersion{}, fmt.Errorf("unsupported language: %s", language)
+	}
+	if version == "" {
+		version = config.DefaultVersion
+	}
+	v, ok := config.Versions[version]
+	if !ok {
+		return LanguageVersion{}, fmt.Errorf("unsupported %s version: %s", language, version)
+	}
+	v.Name = version
+	return v, nil
+}
+
+// ResolveFlags checks requested flags against the language allowlist and splits them by stage.
+// For C and C++ a -std=<version> flag selects that toolchain version instead of passing through.
+func ResolveFlags(language, version string, flags []string) (string, []string, []string, error) {
+	config, ok := languageConfigs[language]
+	if !ok {
+		return "", nil, nil, fmt.Errorf("unsupported language: %s", language)
+	}
+
+	var compileFlags, runFlags []string
+	for _, flag := range flags {
+		if std, ok := strings.CutPrefix(flag, "-std="); ok {
+			if _, known := config.Versions[std]; known && (language == "c" || language == "cpp") {
+				if version != "" && version != std {
+					return "", nil, nil, fmt.Errorf("flag %s conflicts with requested version %s@%s", flag, language, version)
+				}
+				version = std
+				continue
+			}
+		}
+
+		stage, ok := config.AllowedFlags[flag]
+		if !ok {
+			return "", nil, nil, fmt.Errorf("flag %s is not allowed for %s", flag, language)
+		}
+		if stage == StageRun {
+			runFlags = append(runFlags, flag)
+		} else {
+			compileFlags = append(compileFlags, flag)
+		}
+	}
+	return version, compileFlags, runFlags, nil
+}
+
+// SetFlagAllowlist replaces the flag allowlist of a language. Entries prefixed
+// with "run:" are passed to the runtime, everything else to the compiler.
+func SetFlagAllowlist(language string, flags []string) error {
+	config, ok := languageConfigs[language]
+	if !ok {
+		return fmt.Errorf("unsupported language: %s", language)
+	}
+
+	allowed := make(map[string]FlagStage, len(flags))
+	for _, flag := range flags {
+		stage := StageCompile
+		if f, ok := strings.CutPrefix(flag, "run:"); ok {
+			flag, stage = f, StageRun
+		}
+		if !safeFlag.MatchString(flag) {
+			return fmt.Errorf("invalid flag in %s allowlist: %q", language, flag)
+		}
+		allowed[flag] = stage
+	}
+	config.AllowedFlags = allowed
+	languageConfigs[language] = config
+	return nil
+}
+
+// listLanguages reports every language version whose pool, as routed by poolFor,
+// passes the available check
+func listLanguages(poolFor func(language string, version LanguageVersion) string, available func(pool string) bool) []LanguageInfo {
+	var infos []LanguageInfo
+	for language, config := range languageConfigs {
+		info := LanguageInfo{Language: language}
+		for name, v := range config.Versions {
+			if available(poolFor(language, v)) {
+				info.Versions = append(info.Versions, name)
+			}
+		}
+		if len(info.Versions) == 0 {
+			continue
+		}
+		sort.Strings(info.Versions)
+		if available(poolFor(language, config.Versions[config.DefaultVersion])) {
+			info.DefaultVersion = config.DefaultVersion
+		}
+		infos = append(infos, info)
+	}
+	sort.Slice(infos, func(i, j int) bool { return infos[i].Language < infos[j].Language })
+	return infos
+}
+
+// joinFlags renders flags as a space-prefixed argument list, or nothing when empty
+func joinFlags(flags []string) string {
+	if len(flags) == 0 {
+		return ""
+	}
+	return " " + strings.Join(flags, " ")
+}
+
+// command renders the toolchain invocation for a version
+func (v LanguageVersion) command() string {
+	return strings.Join(append([]string{v.Toolchain}, v.Flags...), " ")
+}
+
+// javaPackage matches the package declaration of a Java source file
+var javaPackage = regexp.MustCompile(`(?m)^\s*package\s+([A-Za-z_][A-Za-z0-9_.]*)\s*;`)
+
+// entryClass derives the fully qualified main class from a Java entry file
+func entryClass(entrypoint, content string) string {
+	class := strings.TrimSuffix(path.Base(entrypoint), ".java")
+	if m := javaPackage.FindStringSubmatch(content); m != nil {
+		return m[1] + "." + class
+	}
+	return class
+}
+
+// sortedKeys returns the keys of a set in lexical order
+func sortedKeys(set map[string]bool) []string {
+	keys := make([]string, 0, len(set))
+	for k := range set {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
 }
@@ -27,38 +27,74 @@ const (
 // ContainerInfo holds information about a container
 type ContainerInfo struct {
 	ID    string
+	Pool  string
 	State ContainerState
+	// JobMemoryLimit, in MB, is set while a job runs under a lower memory limit than
+	// its pool's; the cgroup then decides when the job ran out of memory
+	JobMemoryLimit int64
 }
 
 // Job represents a code execution request
 type Job struct {
 	Language string
-	Code     string
-	Input    string
-	Result   chan Result
+	Version  string   // empty selects the language's default version
+	Flags    []string // compiler and runtime flags, checked against the language allowlist
+	Code     string   // single-file source; ignored when Files is set
+	Files    []File   // multi-file project sources
+	// Entrypoint names the file a multi-file project starts from; defaults to the language source file
+	Entrypoint string
+	// Args are passed to the program after its runtime flags; each may only hold
+	// letters, digits and _.+=:,/-
+	Args  []string
+	Input string
+	// Interactor, when set, is started alongside the program with its stdout wired to the
+	// program's stdin and the program's stdout wired to its stdin; Input is then ignored
+	Interactor *Job
+	// TimeLimit bounds the run step, excluding the build; zero uses the language timeout
+	TimeLimit time.Duration
+	// MemoryLimit, in MB, lowers the container's memory limit while the program runs; zero keeps the pool limit
+	MemoryLimit int64
+	Result      chan Result
+	// Stream, when set, receives output chunks while the program runs; it is called from
+	// the goroutines reading the program's output and must be safe for concurrent use
+	Stream func(OutputChunk)
+
+	compileFlags []string
+	runFlags     []string
 }
 
 // Result contains the output of code execution
 type Result struct {
-	Output        string
+	Output        string // stdout and stderr as they interleaved
+	Stdout        string // stdout alone, for programs whose output is data
+	Stderr        string // stderr alone
 	Success       bool
 	Error         error
+	ExitCode      int          // exit status of the program, or -1 if it did not run to completion
+	Interaction   *Interaction // interactor side of an interactive run
+	Memory        int64        // peak resident memory of the run in KB, or 0 if it was not measured
 	ExecutionTime string
+	Version       string // exact language version used, e.g. cpp@gnu++17
 }
 
 // ContainerManager manages Docker containers for the worker pool
 type ContainerManager struct {
 	dockerClient *client.Client
 	containers   map[string]*ContainerInfo
+	pools        map[string]PoolConfig
+	poolOrder    []string
+	routes       map[string]string // language to the pool that serves it
 	mu           sync.Mutex
 	logger       *logrus.Logger
-	maxWorkers   int
-	memorylimit  int64
-	cpunanolimit int64
 }
 
-// NewContainerManager creates a new container manager
-func NewContainerManager(maxWorkers int, memorylimit, cpunanolimit int64) (*ContainerManager, error) {
+// NewContainerManager creates a new container manager for the given pools
+func NewContainerManager(pools []PoolConfig) (*ContainerManager, error) {
+	routes, err := validatePools(pools)
+	if err != nil {
+		return nil, err
+	}
+
 	dockerClient, err := client.NewClientWithOpts(
 		client.FromEnv,
 		client.WithVersion("1.45"),
@@ -89,14 +125,58 @@ func NewContainerManager(maxWorkers int, memorylimit, cpunanolimit int64) (*Cont
 		FullTimestamp: true,
 	})
 
-	return &ContainerManager{
+	cm := &ContainerManager{
 		dockerClient: dockerClient,
 		containers:   make(map[string]*ContainerInfo),
+		pools:        make(map[string]PoolConfig, len(pools)),
+		routes:       routes,
 		logger:       logger,
-		maxWorkers:   maxWorkers,
-		memorylimit:  memorylimit,
-		cpunanolimit: cpunanolimit,
-	}, nil
+	}
+	for _, pool := range pools {
+		cm.pools[pool.Name] = pool
+		cm.poolOrder = append(cm.poolOrder, pool.Name)
+	}
+
+	return cm, nil
+}
+
+// Pools returns the configured pools in declaration order
+func (cm *ContainerManager) Pools() []PoolConfig {
+	pools := make([]PoolConfig, 0, len(cm.poolOrder))
+	for _, name := range cm.poolOrder {
+		pools = append(pools, cm.pools[name])
+	}
+	return pools
+}
+
+// HasPool reports whether a pool with the given name is configured
+func (cm *ContainerManager) HasPool(name string) bool {
+	_, ok := cm.pools[name]
+	return ok
+}
+
+// containerPool resolves which configured pool an existing container belongs to
+func (cm *ContainerManager) containerPool(image string, labels map[string]string) (string, bool) {
+	if name, ok := labels[poolLabel]; ok {
+		_, known := cm.pools[name]
+		return name, known
+	}
+	// Containers started before pools existed carry no label; adopt them into the default pool
+	if pool, ok := cm.pools[DefaultPoolName]; ok && pool.Image == image {
+		return DefaultPoolName, true
+	}
+	return "", false
+}
+
+// poolCount returns the number of tracked containers in a pool; callers must hold cm.mu
+func (cm *ContainerManager) poolCount(pool string) int {
+	count := 0
+	for _, info := range cm.containers {
+		if info.Pool == pool {
+			count++
+		}
+	}
+	return count
 }
 
 // fileHook is a custom logrus hook to write logs to a file
@@ -117,7 +197,7 @@ func (h *fileHook) Fire(entry *logrus.Entry) error {
 	return err
 }
 
-// InitializePool ensures the correct number of containers are running
+// InitializePool ensures the correct number of containers are running in every pool
 func (cm *ContainerManager) InitializePool() error {
 	containers, err := cm.dockerClient.ContainerList(context.Background(), container.ListOptions{All: true})
 	if err != nil {
@@ -127,61 +207,79 @@ func (cm *ContainerManager) InitializePool() error {
 
 	// Register existing worker containers
 	for _, c := range containers {
-		if c.Image == "24321010/worker" {
-			state := StateIdle
-			if c.State != "running" {
-				state = StateError
-			}
-			cm.mu.Lock()
-			cm.containers[c.ID] = &ContainerInfo{ID: c.ID, State: state}
-			cm.mu.Unlock()
-			cm.logger.WithFields(logrus.Fields{
-				"container_id": c.ID[:12],
-				"state":        state,
-			}).Info(color.GreenString("Found existing worker container"))
+		pool, ok := cm.containerPool(c.Image, c.Labels)
+		if !ok {
+			continue
 		}
+		state := StateIdle
+		if c.State != "running" {
+			state = StateError
+		}
+		cm.mu.Lock()
+		cm.containers[c.ID] = &ContainerInfo{ID: c.ID, Pool: pool, State: state}
+		cm.mu.Unlock()
+		cm.logger.WithFields(logrus.Fields{
+			"container_id": c.ID[:12],
+			"pool":         pool,
+			"state":        state,
+		}).Info(color.GreenString("Found existing worker container"))
 	}
 
-	// Adjust container count
-	currentCount := len(cm.containers)
-	if currentCount > cm.maxWorkers {
-		cm.logger.WithFields(logrus.Fields{"count": currentCount}).Warn(color.YellowString("Found %d worker containers, removing excess...", currentCount))
-		excess := currentCount - cm.maxWorkers
-		cm.removeExcessContainers(excess)
-	} else if currentCount < cm.maxWorkers {
-		cm.logger.WithFields(logrus.Fields{
-			"current": currentCount,
-			"needed":  cm.maxWorkers - currentCount,
-		}).Info(color.GreenString("Only %d worker containers found, creating %d more...", currentCount, cm.maxWorkers-currentCount))
-		for i := 0; i < cm.maxWorkers-currentCount; i++ {
-			if err := cm.StartContainer(); err != nil {
-				cm.logger.WithFields(logrus.Fields{"error": err}).Error(color.RedString("Failed to start container"))
+	// Adjust container count per pool
+	for _, name := range cm.poolOrder {
+		size := cm.pools[name].Size
+		cm.mu.Lock()
+		currentCount := cm.poolCount(name)
+		cm.mu.Unlock()
+
+		if currentCount > size {
+			cm.logger.WithFields(logrus.Fields{"pool": name, "count": currentCount}).Warn(color.YellowString("Found %d %s containers, removing excess...", currentCount, name))
+			cm.removeExcessContainers(name, currentCount-size)
+		} else if currentCount < size {
+			cm.logger.WithFields(logrus.Fields{
+				"pool":    name,
+				"current": currentCount,
+				"needed":  size - currentCount,
+			}).Info(color.GreenString("Only %d %s containers found, creating %d more...", currentCount, name, size-currentCount))
+			for i := 0; i < size-currentCount; i++ {
+				if err := cm.StartContainer(name); err != nil {
+					cm.logger.WithFields(logrus.Fields{"pool": name, "error": err}).Error(color.RedString("Failed to start container"))
+				}
 			}
 		}
-	}
 
-	if len(cm.containers) == 0 {
-		cm.logger.Error(color.RedString("Failed to initialize container pool: no containers available"))
-		return fmt.Errorf("failed to initialize container pool: no containers available")
+		cm.mu.Lock()
+		currentCount = cm.poolCount(name)
+		cm.mu.Unlock()
+		if currentCount == 0 {
+			cm.logger.WithFields(logrus.Fields{"pool": name}).Error(color.RedString("Failed to initialize container pool: no containers available"))
+			return fmt.Errorf("failed to initialize container pool %s: no containers available", name)
+		}
 	}
 	return nil
 }
 
-// StartContainer creates and starts a new worker container
-func (cm *ContainerManager) StartContainer() error {
+// StartContainer creates and starts a new worker container in the named pool
+func (cm *ContainerManager) StartContainer(poolName string) error {
 	ctx := context.Background()
 
+	pool, ok := cm.pools[poolName]
+	if !ok {
+		return fmt.Errorf("unknown container pool: %s", poolName)
+	}
+
 	cm.mu.Lock()
-	if len(cm.containers) >= cm.maxWorkers {
+	if count := cm.poolCount(poolName); count >= pool.Size {
 		cm.mu.Unlock()
-		cm.logger.WithFields(logrus.Fields{"count": len(cm.containers)}).Warn(color.YellowString("Already have %d containers, not starting new one", len(cm.containers)))
+		cm.logger.WithFields(logrus.Fields{"pool": poolName, "count": count}).Warn(color.YellowString("Already have %d %s containers, not starting new one", count, poolName))
 		return nil
 	}
 	cm.mu.Unlock()
 
 	config := &container.Config{
-		Image: "24321010/worker",
-		Tty:   true,
+		Image:  pool.Image,
+		Tty:    true,
+		Labels: map[string]string{poolLabel: poolName},
 	}
 
 	// 	seccompProfile := `{
@@ -200,8 +298,8 @@ func (cm *ContainerManager) StartContainer() error {
 
 	hostConfig := &container.HostConfig{
 		Resources: container.Resources{
-			Memory:   (cm.memorylimit) * 1024 * 1024,
-			NanoCPUs: (cm.cpunanolimit) * 1000_000,
+			Memory:   (pool.MemoryLimit) * 1024 * 1024,
+			NanoCPUs: (pool.CPUNanoLimit) * 1000_000,
 			// PidsLimit: &[]int64{20}[0],
 			// Ulimits:   []*container.Ulimit{{Name: "nproc", Hard: 70, Soft: 70}},
 		},
@@ -225,10 +323,11 @@ func (cm *ContainerManager) StartContainer() error {
 	}
 
 	cm.mu.Lock()
-	cm.containers[resp.ID] = &ContainerInfo{ID: resp.ID, State: StateIdle}
+	cm.containers[resp.ID] = &ContainerInfo{ID: resp.ID, Pool: poolName, State: StateIdle}
 	cm.mu.Unlock()
 	cm.logger.WithFields(logrus.Fields{
 		"container_id": resp.ID[:12],
+		"pool":         poolName,
 	}).Info(color.GreenString("Started new worker container"))
 
 	return nil
@@ -251,14 +350,14 @@ func (cm *ContainerManager) RemoveContainer(containerID string) {
 	}).Info(color.GreenString("Removed container"))
 }
 
-// removeExcessContainers removes excess containers beyond maxWorkers
-func (cm *ContainerManager) removeExcessContainers(count int) {
+// removeExcessContainers removes excess containers beyond a pool's size
+func (cm *ContainerManager) removeExcessContainers(pool string, count int) {
 	cm.mu.Lock()
 	defer cm.mu.Unlock()
 
 	var toRemove []string
-	for id := range cm.containers {
-		if len(toRemove) < count {
+	for id, info := range cm.containers {
+		if info.Pool == pool && len(toRemove) < count {
 			toRemove = append(toRemove, id)
 		}
 	}
@@ -279,7 +378,7 @@ func (cm *ContainerManager) MonitorContainers(wg *sync.WaitGroup) {
 	}
 }
 
-// checkHealth ensures container health and count
+// checkHealth ensures container health and count for every pool
 func (cm *ContainerManager) checkHealth() {
 	ctx := context.Background()
 	containers, err := cm.dockerClient.ContainerList(ctx, container.ListOptions{All: true})
@@ -291,19 +390,17 @@ func (cm *ContainerManager) checkHealth() {
 	cm.logger.WithFields(logrus.Fields{"count": len(containers)}).Debug("Checking container health")
 
 	runningWorkers := make(map[string]bool)
+	cm.mu.Lock()
 	for _, c := range containers {
-		if c.Image == "24321010/worker" {
-			if _, exists := cm.containers[c.ID]; exists {
-				if cm.containers[c.ID].State != StateError {
+		if _, ok := cm.containerPool(c.Image, c.Labels); ok {
+			if info, exists := cm.containers[c.ID]; exists {
+				if info.State != StateError {
 					runningWorkers[c.ID] = true
 				}
 			}
 		}
 	}
 
-	// fmt.Print("length : ", len(containers), " workers : ", len(runningWorkers), " \n")
-
-	cm.mu.Lock()
 	var toRemove []string
 	for id := range cm.containers {
 		if !runningWorkers[id] {
@@ -313,53 +410,64 @@ func (cm *ContainerManager) checkHealth() {
 			toRemove = append(toRemove, id)
 		}
 	}
-	currentCount := len(cm.containers) - len(toRemove)
 	cm.mu.Unlock()
 
 	for _, id := range toRemove {
 		cm.RemoveContainer(id)
 	}
 
-	if currentCount < cm.maxWorkers {
-		cm.logger.WithFields(logrus.Fields{
-			"current": currentCount,
-			"needed":  cm.maxWorkers - currentCount,
-		}).Info(color.GreenString("Starting %d replacement containers", cm.maxWorkers-currentCount))
-		for i := 0; i < cm.maxWorkers-currentCount; i++ {
-			if err := cm.StartContainer(); err != nil {
-				cm.logger.WithFields(logrus.Fields{"error": err}).Error(color.RedString("Failed to start replacement container"))
+	for _, name := range cm.poolOrder {
+		size := cm.pools[name].Size
+		cm.mu.Lock()
+		currentCount := cm.poolCount(name)
+		cm.mu.Unlock()
+
+		if currentCount < size {
+			cm.logger.WithFields(logrus.Fields{
+				"pool":    name,
+				"current": currentCount,
+				"needed":  size - currentCount,
+			}).Info(color.GreenString("Starting %d replacement %s containers", size-currentCount, name))
+			for i := 0; i < size-currentCount; i++ {
+				if err := cm.StartContainer(name); err != nil {
+					cm.logger.WithFields(logrus.Fields{"pool": name, "error": err}).Error(color.RedString("Failed to start replacement container"))
+				}
 			}
+		} else if currentCount > size {
+			excess := currentCount - size
+			cm.logger.WithFields(logrus.Fields{"pool": name, "excess": excess}).Warn(color.YellowString("Removing %d excess %s containers", excess, name))
+			cm.removeExcessContainers(name, excess)
 		}
-	} else if len(runningWorkers) > cm.maxWorkers {
-		excess := len(runningWorkers) - cm.maxWorkers
-		cm.logger.WithFields(logrus.Fields{"excess": excess}).Warn(color.YellowString("Removing %d excess containers", excess))
-		cm.removeExcessContainers(excess)
 	}
 }
 
-// GetAvailableContainer finds an idle container
-func (cm *ContainerManager) GetAvailableContainer() (string, error) {
+// GetAvailableContainer finds an idle container in the named pool
+func (cm *ContainerManager) GetAvailableContainer(pool string) (string, error) {
 	const maxRetries = 10
 	const retryDelay = 200 * time.Millisecond
 
-	cm.mu.Lock()
-	for i := 0; i < maxRetries; i++ {
+	if _, ok := cm.pools[pool]; !ok {
+		return "", fmt.Errorf("unknown container pool: %s", pool)
+	}
 
+	for i := 0; i < maxRetries; i++ {
+		cm.mu.Lock()
 		for id, info := range cm.containers {
-			if info.State == StateIdle {
+			if info.Pool == pool && info.State == StateIdle {
 				info.State = StateBusy
 				cm.mu.Unlock()
 				cm.logger.WithFields(logrus.Fields{
 					"container_id": id[:12],
+					"pool":         pool,
 				}).Info(color.GreenString("Assigned container to job"))
 				return id, nil
 			}
 		}
+		cm.mu.Unlock()
 		time.Sleep(retryDelay)
 	}
-	cm.mu.Unlock()
-	cm.logger.WithFields(logrus.Fields{"retries": maxRetries}).Error(color.RedString("No available containers after %d retries", maxRetries))
-	return "", fmt.Errorf("no available containers after %d retries", maxRetries)
+	cm.logger.WithFields(logrus.Fields{"pool": pool, "retries": maxRetries}).Error(color.RedString("No available %s containers after %d retries", pool, maxRetries))
+	return "", fmt.Errorf("%w in pool %s after %d retries", ErrNoContainer, pool, maxRetries)
 }
 
 // SetContainerState updates the state of a container
@@ -415,12 +523,14 @@ func (cm *ContainerManager) ContainerCount() int {
 	return len(cm.containers)
 }
 
-func (cm *ContainerManager) CheckResourceOutsurge(containerID string) bool {
+// CheckResourceOutsurge reports a container that uses more than 99% of its CPU or
+// memory. A memory outsurge wraps ErrMemoryLimitExceeded.
+func (cm *ContainerManager) CheckResourceOutsurge(containerID string) error {
 	ctx := context.Background()
 	info, err := cm.dockerClient.ContainerStatsOneShot(ctx, containerID)
 	if err != nil {
 		cm.logger.WithFields(logrus.Fields{"error": err}).Error(color.RedString("Failed to inspect container"))
-		return false
+		return nil
 	}
 	defer info.Body.Close()
 
@@ -428,7 +538,7 @@ func (cm *ContainerManager) CheckResourceOutsurge(containerID string) bool {
 	data, err := io.ReadAll(info.Body)
 	if err != nil {
 		cm.logger.WithFields(logrus.Fields{"error": err}).Error(color.RedString("Failed to read container stats"))
-		return false
+		return nil
 	}
 
 	//HOTFIX: only parse necessary fields for CPU/memory
@@ -447,7 +557,7 @@ func (cm *ContainerManager) CheckResourceOutsurge(containerID string) bool {
 
 	if err := json.Unmarshal(data, &stats); err != nil {
 		cm.logger.WithFields(logrus.Fields{"error": err}).Error(color.RedString("Failed to unmarshal stats"))
-		return false
+		return nil
 	}
 
 	//Calculate CPU percentage
@@ -462,6 +572,12 @@ func (cm *ContainerManager) CheckResourceOutsurge(containerID string) bool {
 		memPercent = (float64(stats.MemoryStats.Usage) / float64(stats.MemoryStats.Limit)) * 100
 	}
 
+	// A job under its own memory limit is left to the cgroup, whose OOM kill counts as MLE;
+	// removing the container first would lose the counter
+	if cm.jobMemoryLimit(containerID) > 0 {
+		memPercent = 0
+	}
+
 	//TAGGED HOTFIX: threshold check
 	if cpuPercent > 99.0 || memPercent > 99.0 {
 		cm.logger.WithFields(logrus.Fields{
@@ -469,8 +585,11 @@ func (cm *ContainerManager) CheckResourceOutsurge(containerID string) bool {
 			"cpu_percent":    fmt.Sprintf("%.2f%%", cpuPercent),
 			"memory_percent": fmt.Sprintf("%.2f%%", memPercent),
 		}).Error(color.MagentaString("Resource outsurge detected [TAG:resource-hotfix]"))
-		return true
+		if memPercent > 99.0 {
+			return fmt.Errorf("%w: container used %.2f%% of its memory", ErrMemoryLimitExceeded, memPercent)
+		}
+		return fmt.Errorf("container used %.2f%% CPU", cpuPercent)
 	}
 
-	return false
+	return nil
 }
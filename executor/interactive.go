@@ -0,0 +1,222 @@
+package executor
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os/exec"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/fatih/color"
+	logrus "github.com/sirupsen/logrus"
+)
+
+// interactorWorkspace is the workspace, relative to privateRoot, used for the interactor.
+// It holds the test data, so the program it talks to must not be able to read it.
+const interactorWorkspace = "interactor"
+
+// ErrInteractorFailed reports that the interactor could not be built or started, which
+// is the problem's fault rather than the program's
+var ErrInteractorFailed = errors.New("interactor failed")
+
+// maxTranscript caps the recorded exchange between a program and its interactor
+const maxTranscript = 64 << 10
+
+// Interaction reports the interactor side of an interactive run
+type Interaction struct {
+	Output   string // what the interactor wrote to stderr, usually its verdict comment
+	ExitCode int    // exit status of the interactor, or -1 if it did not run to completion
+	Error    error
+	// Transcript is the data exchanged, line by line; "> " marks program output and
+	// "< " marks interactor output. It is truncated after 64KB.
+	Transcript string
+}
+
+// transcript records the exchange between a program and its interactor
+type transcript struct {
+	mu        sync.Mutex
+	buf       strings.Builder
+	truncated bool
+}
+
+func (t *transcript) record(prefix, data string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.truncated {
+		return
+	}
+	for _, line := range strings.SplitAfter(data, "\n") {
+		if line == "" {
+			continue
+		}
+		if t.buf.Len()+len(prefix)+len(line) > maxTranscript {
+			t.buf.WriteString("...\n")
+			t.truncated = true
+			return
+		}
+		t.buf.WriteString(prefix)
+		t.buf.WriteString(line)
+		if !strings.HasSuffix(line, "\n") {
+			t.buf.WriteString("\n")
+		}
+	}
+}
+
+func (t *transcript) String() string {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.buf.String()
+}
+
+// relay copies one side's stdout into the other side's stdin, recording what passes.
+// It keeps draining src after dst is gone so the writer never blocks, and closes dst at EOF.
+func relay(src io.Reader, dst io.WriteCloser, record func(string)) {
+	defer dst.Close()
+	buf := make([]byte, 4096)
+	writable := true
+	for {
+		n, err := src.Read(buf)
+		if n > 0 {
+			record(string(buf[:n]))
+			if writable {
+				if _, werr := dst.Write(buf[:n]); werr != nil {
+					writable = false
+				}
+			}
+		}
+		if err != nil {
+			return
+		}
+	}
+}
+
+// executeInteractive runs a program against its interactor in one container. Both
+// are built in their own workspaces first, then run under their own time limits.
+func (p *WorkerPool) executeInteractive(containerID string, job Job) (string, bool, *Interaction, error) {
+	interactor := *job.Interactor
+	config, ok := GetLanguageConfig(job.Language)
+	if !ok {
+		return "", false, nil, fmt.Errorf("unsupported language: %s", job.Language)
+	}
+	interactorConfig, ok := GetLanguageConfig(interactor.Language)
+	if !ok {
+		return "", false, nil, fmt.Errorf("unsupported interactor language: %s", interactor.Language)
+	}
+	version, err := ResolveVersion(job.Language, job.Version)
+	if err != nil {
+		return "", false, nil, err
+	}
+	interactorVersion, err := ResolveVersion(interactor.Language, interactor.Version)
+	if err != nil {
+		return "", false, nil, err
+	}
+
+	if err := p.containerMgr.PrepareWorkspace(containerID, jobWorkspace, job.Files, ""); err != nil {
+		return "", false, nil, err
+	}
+	if err := p.containerMgr.PrepareWorkspace(containerID, interactorWorkspace, interactor.Files, ""); err != nil {
+		return "", false, nil, err
+	}
+	// Stopping the docker exec clients does not stop what they started in the container
+	defer p.containerMgr.KillWorkspace(containerID, interactorWorkspace)
+	defer p.containerMgr.KillWorkspace(containerID, jobWorkspace)
+
+	output := &outputCollector{notify: job.Stream}
+	comment := &outputCollector{}
+	spec := buildSpec(job, version, jobWorkspace)
+	interactorSpec := buildSpec(interactor, interactorVersion, interactorWorkspace)
+	if buildLog, err := p.build(context.Background(), containerID, interactorConfig, interactorSpec); err != nil {
+		return "", false, nil, fmt.Errorf("%w to build: %v: %s", ErrInteractorFailed, err, buildLog)
+	}
+	if buildLog, err := p.build(context.Background(), containerID, config, spec); err != nil {
+		fmt.Fprint(output.writer("stderr"), buildLog)
+		return output.String(), false, nil, err
+	}
+
+	restore, err := p.containerMgr.applyMemoryLimit(containerID, job.MemoryLimit)
+	if err != nil {
+		return "", false, nil, err
+	}
+	defer restore()
+	oomBefore, _ := p.containerMgr.OOMKills(containerID)
+
+	ctx, cancel := context.WithTimeout(context.Background(), runTimeout(job, config))
+	defer cancel()
+	interactorCtx, interactorCancel := context.WithTimeout(context.Background(), runTimeout(interactor, interactorConfig))
+	defer interactorCancel()
+
+	program := exec.CommandContext(ctx, "docker", append(execArgs(containerID, spec.Workdir, true), config.InteractiveRunArgs(spec)...)...)
+	judge := exec.CommandContext(interactorCtx, "docker", append(execArgs(containerID, interactorSpec.Workdir, true), interactorConfig.InteractiveRunArgs(interactorSpec)...)...)
+	program.Stderr = output.writer("stderr")
+	judge.Stderr = comment.writer("stderr")
+
+	programIn, err := program.StdinPipe()
+	if err != nil {
+		return "", false, nil, err
+	}
+	programOut, err := program.StdoutPipe()
+	if err != nil {
+		return "", false, nil, err
+	}
+	judgeIn, err := judge.StdinPipe()
+	if err != nil {
+		return "", false, nil, err
+	}
+	judgeOut, err := judge.StdoutPipe()
+	if err != nil {
+		return "", false, nil, err
+	}
+
+	start := time.Now()
+	if err := judge.Start(); err != nil {
+		return "", false, nil, fmt.Errorf("%w to start: %v", ErrInteractorFailed, err)
+	}
+	if err := program.Start(); err != nil {
+		interactorCancel()
+		judge.Wait()
+		return "", false, nil, fmt.Errorf("failed to start program: %w", err)
+	}
+
+	exchange := &transcript{}
+	var relays sync.WaitGroup
+	relays.Add(2)
+	go func() {
+		defer relays.Done()
+		relay(programOut, judgeIn, func(data string) { exchange.record("> ", data) })
+	}()
+	go func() {
+		defer relays.Done()
+		relay(judgeOut, programIn, func(data string) { exchange.record("< ", data) })
+	}()
+
+	relays.Wait()
+	programErr := program.Wait()
+	judgeErr := judge.Wait()
+	duration := time.Since(start)
+
+	interaction := &Interaction{
+		Output:     comment.String(),
+		ExitCode:   exitCode(judgeErr),
+		Transcript: exchange.String(),
+	}
+	if judgeErr != nil {
+		interaction.Error = p.containerMgr.runError(interactorCtx, containerID, oomBefore, judgeErr)
+	}
+
+	p.logger.WithFields(logrus.Fields{
+		"containerID":        containerID[:12],
+		"language":           job.Language,
+		"interactor":         interactor.Language,
+		"duration":           duration,
+		"error":              programErr,
+		"interactorExitCode": interaction.ExitCode,
+	}).Debug(color.GreenString("Interactive run completed in container %s", containerID[:12]))
+
+	if programErr != nil {
+		return output.String(), false, interaction, p.containerMgr.runError(ctx, containerID, oomBefore, programErr)
+	}
+	return output.String(), true, interaction, nil
+}
@@ -0,0 +1,188 @@
+package executor
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"os/exec"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/docker/docker/api/types/container"
+)
+
+var (
+	ErrTimeLimitExceeded   = errors.New("time limit exceeded")
+	ErrMemoryLimitExceeded = errors.New("memory limit exceeded")
+)
+
+// cgroupMemoryEvents lists where the OOM kill counter lives, for cgroup v2 and v1
+var cgroupMemoryEvents = []string{
+	"/sys/fs/cgroup/memory.events",
+	"/sys/fs/cgroup/memory/memory.oom_control",
+}
+
+// poolMemoryLimit returns the memory limit, in MB, of the pool that owns a container
+func (cm *ContainerManager) poolMemoryLimit(containerID string) int64 {
+	cm.mu.Lock()
+	defer cm.mu.Unlock()
+	info, ok := cm.containers[containerID]
+	if !ok {
+		return 0
+	}
+	return cm.pools[info.Pool].MemoryLimit
+}
+
+// jobMemoryLimit returns the memory limit, in MB, of the job running in a container,
+// or 0 when it runs under its pool's limit
+func (cm *ContainerManager) jobMemoryLimit(containerID string) int64 {
+	cm.mu.Lock()
+	defer cm.mu.Unlock()
+	if info, ok := cm.containers[containerID]; ok {
+		return info.JobMemoryLimit
+	}
+	return 0
+}
+
+// setJobMemoryLimit records the memory limit of the job running in a container
+func (cm *ContainerManager) setJobMemoryLimit(containerID string, limit int64) {
+	cm.mu.Lock()
+	defer cm.mu.Unlock()
+	if info, ok := cm.containers[containerID]; ok {
+		info.JobMemoryLimit = limit
+	}
+}
+
+// SetMemoryLimit changes the memory limit of a running container, in MB
+func (cm *ContainerManager) SetMemoryLimit(containerID string, limit int64) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	bytes := limit * 1024 * 1024
+	_, err := cm.dockerClient.ContainerUpdate(ctx, containerID, container.UpdateConfig{
+		Resources: container.Resources{Memory: bytes, MemorySwap: bytes},
+	})
+	if err != nil {
+		return fmt.Errorf("failed to set memory limit: %v", err)
+	}
+	return nil
+}
+
+// OOMKills returns how many processes the kernel has killed in the container for
+// running out of memory
+func (cm *ContainerManager) OOMKills(containerID string) (int, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	for _, path := range cgroupMemoryEvents {
+		out, err := exec.CommandContext(ctx, "docker", "exec", containerID, "cat", path).Output()
+		if err != nil {
+			continue
+		}
+		scanner := bufio.NewScanner(strings.NewReader(string(out)))
+		for scanner.Scan() {
+			fields := strings.Fields(scanner.Text())
+			if len(fields) == 2 && fields[0] == "oom_kill" {
+				return strconv.Atoi(fields[1])
+			}
+		}
+	}
+	return 0, fmt.Errorf("no cgroup OOM counter in container %s", containerID[:12])
+}
+
+// OOMKilled reports whether Docker recorded the container itself as killed for running out of memory
+func (cm *ContainerManager) OOMKilled(containerID string) bool {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	info, err := cm.dockerClient.ContainerInspect(ctx, containerID)
+	if err != nil || info.State == nil {
+		return false
+	}
+	return info.State.OOMKilled
+}
+
+// runUsage is what the time utility recorded about a run
+type runUsage struct {
+	Memory int64         // peak resident memory in KB
+	CPU    time.Duration // user plus system time of the program itself
+}
+
+// readUsage reads what a run in the workspace recorded; it reports false when nothing
+// was recorded, e.g. when the image has no time utility or the run was killed
+func (cm *ContainerManager) readUsage(containerID, workspace string) (runUsage, bool) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	dir := workspacePath(workspace)
+	out, err := exec.CommandContext(ctx, "docker", append(execArgs(containerID, dir, false), "cat", dir+"/"+rusageFile)...).Output()
+	if err != nil {
+		return runUsage{}, false
+	}
+	// The time utility may note an abnormal exit before the figures, which come last
+	fields := strings.Fields(string(out))
+	if len(fields) == 0 {
+		return runUsage{}, false
+	}
+	figures := strings.Split(fields[len(fields)-1], ",")
+	if len(figures) != 3 {
+		return runUsage{}, false
+	}
+	kb, err := strconv.ParseInt(figures[0], 10, 64)
+	if err != nil {
+		return runUsage{}, false
+	}
+	var cpu time.Duration
+	for _, figure := range figures[1:] {
+		seconds, err := strconv.ParseFloat(figure, 64)
+		if err != nil {
+			return runUsage{}, false
+		}
+		cpu += time.Duration(seconds * float64(time.Second))
+	}
+	return runUsage{Memory: kb, CPU: cpu}, true
+}
+
+// applyMemoryLimit lowers a container's memory limit for one job and returns the
+// function that restores the pool limit. Job limits never exceed the pool limit. While
+// the job limit holds, the resource watchdog leaves memory to the cgroup.
+func (cm *ContainerManager) applyMemoryLimit(containerID string, limit int64) (func(), error) {
+	poolLimit := cm.poolMemoryLimit(containerID)
+	if limit <= 0 || (poolLimit > 0 && limit >= poolLimit) {
+		return func() {}, nil
+	}
+	if err := cm.SetMemoryLimit(containerID, limit); err != nil {
+		return nil, err
+	}
+	cm.setJobMemoryLimit(containerID, limit)
+	return func() {
+		// Cleared only once the pool limit is back, so the watchdog never measures
+		// against the job's limit
+		defer cm.setJobMemoryLimit(containerID, 0)
+		if poolLimit <= 0 {
+			return
+		}
+		if err := cm.SetMemoryLimit(containerID, poolLimit); err != nil {
+			// A container stuck at a job's limit would starve later jobs
+			cm.RemoveContainer(containerID)
+		}
+	}, nil
+}
+
+// runError classifies a failed run: the time limit when its deadline passed, the
+// memory limit when the kernel killed something for memory during the run, and an
+// execution error otherwise
+func (cm *ContainerManager) runError(ctx context.Context, containerID string, oomBefore int, err error) error {
+	if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+		return fmt.Errorf("%w: %w", ErrTimeLimitExceeded, ctx.Err())
+	}
+	if after, countErr := cm.OOMKills(containerID); countErr == nil && after > oomBefore {
+		return fmt.Errorf("%w: %w", ErrMemoryLimitExceeded, err)
+	}
+	if cm.OOMKilled(containerID) {
+		return fmt.Errorf("%w: %w", ErrMemoryLimitExceeded, err)
+	}
+	return fmt.Errorf("execution error: %w", err)
+}
@@ -0,0 +1,91 @@
+package executor
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// DefaultPoolName is the pool that serves any language not claimed by another pool
+const DefaultPoolName = "general"
+
+// poolLabel tags worker containers with the name of the pool that owns them
+const poolLabel = "xcodeengine.pool"
+
+// PoolConfig describes a named group of worker containers that share an image and resource limits
+type PoolConfig struct {
+	Name         string
+	Image        string
+	Size         int
+	MemoryLimit  int64    // in MB
+	CPUNanoLimit int64    // in thousandths of a CPU
+	Languages    []string // languages routed to this pool
+}
+
+// validatePools checks pool definitions and returns which pool serves each language.
+// Languages no pool claims go to the DefaultPoolName pool, which must then exist.
+func validatePools(pools []PoolConfig) (map[string]string, error) {
+	if len(pools) == 0 {
+		return nil, fmt.Errorf("at least one container pool is required")
+	}
+
+	seen := make(map[string]bool)
+	routes := make(map[string]string, len(languageConfigs))
+	for _, pool := range pools {
+		if pool.Name == "" || pool.Image == "" {
+			return nil, fmt.Errorf("container pool requires a name and an image")
+		}
+		if seen[pool.Name] {
+			return nil, fmt.Errorf("duplicate container pool: %s", pool.Name)
+		}
+		if pool.Size <= 0 {
+			return nil, fmt.Errorf("container pool %s must have a positive size", pool.Name)
+		}
+		seen[pool.Name] = true
+
+		for _, language := range pool.Languages {
+			if _, ok := languageConfigs[language]; !ok {
+				return nil, fmt.Errorf("container pool %s lists unsupported language: %s", pool.Name, language)
+			}
+			if owner, ok := routes[language]; ok {
+				return nil, fmt.Errorf("language %s is claimed by pools %s and %s", language, owner, pool.Name)
+			}
+			routes[language] = pool.Name
+		}
+	}
+
+	var unclaimed []string
+	for language, config := range languageConfigs {
+		if _, ok := routes[language]; ok {
+			continue
+		}
+		if config.Pool != "" && seen[config.Pool] {
+			routes[language] = config.Pool
+			continue
+		}
+		routes[language] = DefaultPoolName
+		unclaimed = append(unclaimed, language)
+	}
+	if len(unclaimed) > 0 && !seen[DefaultPoolName] {
+		sort.Strings(unclaimed)
+		return nil, fmt.Errorf("languages %s are not claimed by any pool and there is no %s pool", strings.Join(unclaimed, ", "), DefaultPoolName)
+	}
+
+	return routes, nil
+}
+
+// poolForLanguage returns the name of the container pool that serves a language
+func (cm *ContainerManager) poolForLanguage(language string) string {
+	if pool, ok := cm.routes[language]; ok {
+		return pool
+	}
+	return DefaultPoolName
+}
+
+// poolForVersion returns the container pool for a specific language version
+func (cm *ContainerManager) poolForVersion(language string, version LanguageVersion) string {
+	if version.Pool != "" {
+		return version.Pool
+	}
+	return cm.poolForLanguage(language)
+}
@@ -0,0 +1,324 @@
+package executor
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os/exec"
+	"sync"
+	"time"
+
+	"github.com/fatih/color"
+	logrus "github.com/sirupsen/logrus"
+)
+
+// maxSessionOutput caps how much output an interactive session may produce
+const maxSessionOutput = 1 << 20
+
+var (
+	ErrSessionTimeout     = errors.New("session time limit exceeded")
+	ErrSessionIdle        = errors.New("session idle timeout")
+	ErrSessionOutputLimit = errors.New("session output limit exceeded")
+	ErrSessionClosed      = errors.New("session closed")
+	// ErrSessionsBusy reports that a pool has no container to spare for another session
+	ErrSessionsBusy = errors.New("no container free for an interactive session")
+)
+
+// SessionLimits bounds an interactive session
+type SessionLimits struct {
+	MaxDuration time.Duration // total wall time, including the build
+	IdleTimeout time.Duration // longest stretch without stdin or output
+	MaxPerPool  int           // sessions one pool may host at once; a pool always keeps a container for jobs
+}
+
+// DefaultSessionLimits applies when no limits are configured
+var DefaultSessionLimits = SessionLimits{
+	MaxDuration: 2 * time.Minute,
+	IdleTimeout: 30 * time.Second,
+	MaxPerPool:  1,
+}
+
+// OutputChunk is a piece of program output as it was produced
+type OutputChunk struct {
+	Stream string // "stdout" or "stderr"
+	Data   string
+}
+
+// SessionResult describes how an interactive session ended
+type SessionResult struct {
+	ExitCode int
+	Error    error
+	Duration time.Duration
+}
+
+// Session is an interactive program run with stdin and output attached to the caller.
+// It holds a pooled container until the program exits or a limit is hit.
+type Session struct {
+	Version string // exact language version, e.g. python@3.12
+
+	output      chan OutputChunk
+	stdin       io.WriteCloser
+	cancel      context.CancelFunc
+	activity    chan struct{}
+	done        chan struct{}
+	result      SessionResult
+	stopReason  error
+	mu          sync.Mutex
+	stdinClosed bool
+}
+
+// StartSession builds a job in a pooled container and starts it interactively.
+// The job's Input is ignored; stdin is fed through Write.
+func (p *WorkerPool) StartSession(job Job) (*Session, error) {
+	poolName, err := p.resolveJob(&job)
+	if err != nil {
+		return nil, err
+	}
+	version, err := ResolveVersion(job.Language, job.Version)
+	if err != nil {
+		return nil, err
+	}
+	config, _ := GetLanguageConfig(job.Language)
+	limits := p.sessionLimits
+
+	if err := p.reserveSession(poolName, limits); err != nil {
+		return nil, err
+	}
+	started := false
+	defer func() {
+		if !started {
+			p.releaseSession(poolName)
+		}
+	}()
+
+	containerID, err := p.containerMgr.GetAvailableContainer(poolName)
+	if err != nil {
+		return nil, err
+	}
+	p.containerMgr.SetContainerState(containerID, StateBusy)
+
+	if err := p.containerMgr.PrepareWorkspace(containerID, jobWorkspace, job.Files, ""); err != nil {
+		p.containerMgr.SetContainerState(containerID, StateIdle)
+		return nil, err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), limits.MaxDuration)
+	spec := buildSpec(job, version, jobWorkspace)
+	cmd := exec.CommandContext(ctx, "docker", append(execArgs(containerID, spec.Workdir, true), config.InteractiveArgs(spec)...)...)
+
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		cancel()
+		p.containerMgr.SetContainerState(containerID, StateIdle)
+		return nil, err
+	}
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		cancel()
+		p.containerMgr.SetContainerState(containerID, StateIdle)
+		return nil, err
+	}
+	stderr, err := cmd.StderrPipe()
+	if err != nil {
+		cancel()
+		p.containerMgr.SetContainerState(containerID, StateIdle)
+		return nil, err
+	}
+
+	start := time.Now()
+	if err := cmd.Start(); err != nil {
+		cancel()
+		p.containerMgr.SetContainerState(containerID, StateIdle)
+		return nil, fmt.Errorf("failed to start session: %w", err)
+	}
+
+	started = true
+	s := &Session{
+		Version:  job.Language + "@" + version.Name,
+		output:   make(chan OutputChunk, 64),
+		stdin:    stdin,
+		cancel:   cancel,
+		activity: make(chan struct{}, 1),
+		done:     make(chan struct{}),
+	}
+
+	p.logger.WithFields(logrus.Fields{
+		"containerID": containerID[:12],
+		"language":    job.Language,
+		"version":     version.Name,
+	}).Info(color.GreenString("Interactive session started in container %s", containerID[:12]))
+
+	var produced int64
+	var producedMu sync.Mutex
+	var readers sync.WaitGroup
+	pump := func(stream string, r io.Reader) {
+		defer readers.Done()
+		buf := make([]byte, 4096)
+		for {
+			n, err := r.Read(buf)
+			if n > 0 {
+				producedMu.Lock()
+				produced += int64(n)
+				over := produced > maxSessionOutput
+				producedMu.Unlock()
+				if over {
+					s.stop(ErrSessionOutputLimit)
+					return
+				}
+				s.touch()
+				s.output <- OutputChunk{Stream: stream, Data: string(buf[:n])}
+			}
+			if err != nil {
+				return
+			}
+		}
+	}
+	readers.Add(2)
+	go pump("stdout", stdout)
+	go pump("stderr", stderr)
+
+	// Watchdog for the idle timeout; the total time limit is enforced by ctx
+	go func() {
+		timer := time.NewTimer(limits.IdleTimeout)
+		defer timer.Stop()
+		for {
+			select {
+			case <-s.done:
+				return
+			case <-s.activity:
+				timer.Reset(limits.IdleTimeout)
+			case <-timer.C:
+				s.stop(ErrSessionIdle)
+				return
+			}
+		}
+	}()
+
+	go func() {
+		readers.Wait()
+		err := cmd.Wait()
+		duration := time.Since(start)
+
+		p.containerMgr.KillWorkspace(containerID, jobWorkspace)
+		p.containerMgr.SetContainerState(containerID, StateIdle)
+		p.releaseSession(poolName)
+
+		result := SessionResult{Duration: duration}
+		var exitErr *exec.ExitError
+		if errors.As(err, &exitErr) {
+			result.ExitCode = exitErr.ExitCode()
+		}
+		s.mu.Lock()
+		switch {
+		case s.stopReason != nil:
+			result.Error = s.stopReason
+		case ctx.Err() == context.DeadlineExceeded:
+			result.Error = ErrSessionTimeout
+		case err != nil && exitErr == nil:
+			result.Error = err
+		}
+		s.result = result
+		s.mu.Unlock()
+
+		cancel()
+		close(s.output)
+		close(s.done)
+
+		p.logger.WithFields(logrus.Fields{
+			"containerID": containerID[:12],
+			"duration":    duration,
+			"exitCode":    result.ExitCode,
+			"error":       result.Error,
+		}).Info(color.GreenString("Interactive session ended in container %s", containerID[:12]))
+	}()
+
+	return s, nil
+}
+
+// reserveSession counts a new session against its pool. Sessions hold their container
+// outside the job queue, so they may never take the last container of a pool.
+func (p *WorkerPool) reserveSession(poolName string, limits SessionLimits) error {
+	limit := min(limits.MaxPerPool, p.containerMgr.pools[poolName].Size-1)
+
+	p.sessionMu.Lock()
+	defer p.sessionMu.Unlock()
+	if p.sessions[poolName] >= limit {
+		return fmt.Errorf("%w in pool %s (%d of %d sessions running)", ErrSessionsBusy, poolName, p.sessions[poolName], max(limit, 0))
+	}
+	p.sessions[poolName]++
+	return nil
+}
+
+// releaseSession frees the slot a session held in its pool
+func (p *WorkerPool) releaseSession(poolName string) {
+	p.sessionMu.Lock()
+	p.sessions[poolName]--
+	p.sessionMu.Unlock()
+}
+
+// SetSessionLimits changes the limits applied to new interactive sessions
+func (p *WorkerPool) SetSessionLimits(limits SessionLimits) {
+	p.sessionLimits = limits
+}
+
+// Output streams program output; it is closed when the program exits.
+// Callers must drain it, or the session cannot finish and release its container.
+func (s *Session) Output() <-chan OutputChunk {
+	return s.output
+}
+
+// Write sends data to the program's stdin
+func (s *Session) Write(data []byte) error {
+	s.mu.Lock()
+	closed := s.stdinClosed
+	s.mu.Unlock()
+	if closed {
+		return ErrSessionClosed
+	}
+	s.touch()
+	_, err := s.stdin.Write(data)
+	return err
+}
+
+// CloseStdin signals end of input to the program
+func (s *Session) CloseStdin() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.stdinClosed {
+		return nil
+	}
+	s.stdinClosed = true
+	return s.stdin.Close()
+}
+
+// Kill stops the program; the session ends once its output is drained
+func (s *Session) Kill() {
+	s.stop(ErrSessionClosed)
+}
+
+// Wait blocks until the program has exited and returns how it ended
+func (s *Session) Wait() SessionResult {
+	<-s.done
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.result
+}
+
+// stop records why the session is being ended and kills the program
+func (s *Session) stop(reason error) {
+	s.mu.Lock()
+	if s.stopReason == nil {
+		s.stopReason = reason
+	}
+	s.mu.Unlock()
+	s.cancel()
+}
+
+// touch marks the session as active for the idle timeout
+func (s *Session) touch() {
+	select {
+	case s.activity <- struct{}{}:
+	default:
+	}
+}
@@ -0,0 +1,62 @@
+package executor
+
+import (
+	"bytes"
+	"sync"
+)
+
+// outputCollector gathers the combined stdout and stderr of a run, and each stream
+// apart, while forwarding every chunk to an optional listener as soon as it is read
+type outputCollector struct {
+	mu      sync.Mutex
+	buf     bytes.Buffer
+	streams map[string]*bytes.Buffer
+	notify  func(OutputChunk)
+}
+
+// streamWriter is the io.Writer for one stream of an outputCollector
+type streamWriter struct {
+	collector *outputCollector
+	stream    string
+}
+
+// writer returns the io.Writer that feeds the named stream into the collector
+func (c *outputCollector) writer(stream string) *streamWriter {
+	return &streamWriter{collector: c, stream: stream}
+}
+
+func (w *streamWriter) Write(p []byte) (int, error) {
+	c := w.collector
+	c.mu.Lock()
+	c.buf.Write(p)
+	if c.streams == nil {
+		c.streams = make(map[string]*bytes.Buffer)
+	}
+	if c.streams[w.stream] == nil {
+		c.streams[w.stream] = &bytes.Buffer{}
+	}
+	c.streams[w.stream].Write(p)
+	c.mu.Unlock()
+
+	if c.notify != nil {
+		c.notify(OutputChunk{Stream: w.stream, Data: string(p)})
+	}
+	return len(p), nil
+}
+
+// String returns everything collected so far
+func (c *outputCollector) String() string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.buf.String()
+}
+
+// stream returns what was collected from one stream
+func (c *outputCollector) stream(name string) string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if buf := c.streams[name]; buf != nil {
+		return buf.String()
+	}
+	return ""
+}
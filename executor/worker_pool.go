@@ -3,6 +3,7 @@ package executor
 import (
 	"bytes"
 	"context"
+	"errors"
 	"fmt"
 	"log"
 	"os/exec"
@@ -15,22 +16,38 @@ import (
 	zap_betterstack "xcodeengine/logger"
 )
 
+// DefaultJudgeParallelism applies when no parallelism cap is configured
+const DefaultJudgeParallelism = 4
+
+var (
+	// ErrBuildFailed reports that a job's sources did not compile
+	ErrBuildFailed = errors.New("build failed")
+	// ErrJobQueueFull reports that a job was turned away because its pool's queue was full
+	ErrJobQueueFull = errors.New("job queue full")
+	// ErrNoContainer reports that no container of a job's pool became idle in time
+	ErrNoContainer = errors.New("no available container")
+)
+
 // WorkerPool manages a pool of workers for code execution
 type WorkerPool struct {
-	jobs         chan Job
-	containerMgr *ContainerManager
-	logger       *logrus.Logger
-	maxWorkers   int
-	maxJobCount  int
-	wg           sync.WaitGroup
-	shutdownChan chan struct{}
+	jobs          map[string]chan Job // one queue per container pool
+	containerMgr  *ContainerManager
+	logger        *logrus.Logger
+	maxWorkers    int
+	maxJobCount   int
+	sessionLimits SessionLimits
+	sessions      map[string]int // running interactive sessions per pool
+	sessionMu     sync.Mutex
+	parallelism   int // tests of one submission that may run at once
+	wg            sync.WaitGroup
+	shutdownChan  chan struct{}
 
 	zap_betterstack *zap_betterstack.BetterStackLogStreamer
 }
 
-// NewWorkerPool initializes a new worker pool
-func NewWorkerPool(maxWorkers, maxJobCount int, memorylimit, cpunanolimit int64, zap_betterstack *zap_betterstack.BetterStackLogStreamer) (*WorkerPool, error) {
-	containerMgr, err := NewContainerManager(maxWorkers, memorylimit, cpunanolimit)
+// NewWorkerPool initializes a new worker pool with one set of workers per container pool
+func NewWorkerPool(pools []PoolConfig, maxJobCount int, zap_betterstack *zap_betterstack.BetterStackLogStreamer) (*WorkerPool, error) {
+	containerMgr, err := NewContainerManager(pools)
 	if err != nil {
 		log.Printf("error initializing container manager: %v", err)
 		return nil, err
@@ -38,12 +55,22 @@ func NewWorkerPool(maxWorkers, maxJobCount int, memorylimit, cpunanolimit int64,
 
 	log.Print("creating worker pool...")
 
+	maxWorkers := 0
+	jobs := make(map[string]chan Job, len(pools))
+	for _, cfg := range pools {
+		jobs[cfg.Name] = make(chan Job, maxJobCount)
+		maxWorkers += cfg.Size
+	}
+
 	pool := &WorkerPool{
-		jobs:            make(chan Job, maxJobCount),
+		jobs:            jobs,
 		containerMgr:    containerMgr,
 		logger:          containerMgr.logger,
 		maxWorkers:      maxWorkers,
 		maxJobCount:     maxJobCount,
+		sessionLimits:   DefaultSessionLimits,
+		sessions:        make(map[string]int, len(pools)),
+		parallelism:     DefaultJudgeParallelism,
 		shutdownChan:    make(chan struct{}),
 		zap_betterstack: zap_betterstack,
 	}
@@ -57,9 +84,13 @@ func NewWorkerPool(maxWorkers, maxJobCount int, memorylimit, cpunanolimit int64,
 	pool.wg.Add(1)
 	go containerMgr.MonitorContainers(&pool.wg)
 
-	for i := 0; i < maxWorkers; i++ {
-		pool.wg.Add(1)
-		go pool.worker(i + 1)
+	workerID := 0
+	for _, cfg := range pools {
+		for i := 0; i < cfg.Size; i++ {
+			workerID++
+			pool.wg.Add(1)
+			go pool.worker(workerID, cfg.Name)
+		}
 	}
 
 	pool.logger.WithFields(logrus.Fields{
@@ -69,27 +100,22 @@ func NewWorkerPool(maxWorkers, maxJobCount int, memorylimit, cpunanolimit int64,
 	return pool, nil
 }
 
-// worker processes jobs from the queue
-func (p *WorkerPool) worker(id int) {
+// worker processes jobs from the queue of its container pool
+func (p *WorkerPool) worker(id int, poolName string) {
 	defer p.wg.Done()
 	p.logger.WithFields(logrus.Fields{
 		"workerID": id,
-	}).Info(color.GreenString("Worker %d started", id))
+		"pool":     poolName,
+	}).Info(color.GreenString("Worker %d started for pool %s", id, poolName))
 
 	for {
 		select {
-		case job, ok := <-p.jobs:
-			if !ok {
-				p.logger.WithFields(logrus.Fields{
-					"workerID": id,
-				}).Info(color.GreenString("Worker %d shutting down due to closed channel", id))
-				return
-			}
+		case job := <-p.jobs[poolName]:
 			p.logger.WithFields(logrus.Fields{
 				"workerID": id,
 				"language": job.Language,
 			}).Debug("received job for processing")
-			p.executeJob(id, job)
+			p.executeJob(id, poolName, job)
 		case <-p.shutdownChan:
 			p.logger.WithFields(logrus.Fields{
 				"workerID": id,
@@ -100,13 +126,14 @@ func (p *WorkerPool) worker(id int) {
 }
 
 // executeJob handles the execution of a single job
-func (p *WorkerPool) executeJob(workerID int, job Job) {
+func (p *WorkerPool) executeJob(workerID int, poolName string, job Job) {
 	p.logger.WithFields(logrus.Fields{
 		"workerID": workerID,
+		"pool":     poolName,
 		"language": job.Language,
 	}).Info("requesting available container")
 
-	containerID, err := p.containerMgr.GetAvailableContainer()
+	containerID, err := p.containerMgr.GetAvailableContainer(poolName)
 	if err != nil {
 		p.logger.WithFields(logrus.Fields{
 			"workerID":    workerID,
@@ -125,8 +152,27 @@ func (p *WorkerPool) executeJob(workerID int, job Job) {
 	p.containerMgr.SetContainerState(containerID, StateBusy)
 
 	start := time.Now()
-	output, success, err := p.executeCode(containerID, job.Language, job.Code, job.Input)
+	var (
+		output      string
+		stdout      string
+		stderr      string
+		usage       runUsage
+		success     bool
+		interaction *Interaction
+	)
+	if job.Interactor != nil {
+		output, success, interaction, err = p.executeInteractive(containerID, job)
+	} else {
+		collected := &outputCollector{notify: job.Stream}
+		usage, success, err = p.executeCode(containerID, job, collected)
+		output, stdout, stderr = collected.String(), collected.stream("stdout"), collected.stream("stderr")
+	}
 	duration := time.Since(start)
+	// Programs report the time they ran for; interactive runs report the whole exchange
+	elapsed := duration
+	if job.Interactor == nil {
+		elapsed = usage.CPU
+	}
 
 	p.containerMgr.SetContainerState(containerID, StateIdle)
 
@@ -154,28 +200,44 @@ func (p *WorkerPool) executeJob(workerID int, job Job) {
 
 	job.Result <- Result{
 		Output:        output,
+		Stdout:        stdout,
+		Stderr:        stderr,
 		Success:       success,
 		Error:         err,
-		ExecutionTime: fmt.Sprintf("%dms", duration.Milliseconds()),
+		ExitCode:      exitCode(err),
+		Memory:        usage.Memory,
+		Interaction:   interaction,
+		ExecutionTime: fmt.Sprintf("%dms", elapsed.Milliseconds()),
+		Version:       job.Language + "@" + job.Version,
 	}
 }
 
-// executeCode runs code in a container
-func (p *WorkerPool) executeCode(containerID, language, code, stdin string) (string, bool, error) {
+// executeCode runs code in a container, collecting its output in output, and reports
+// its peak memory and CPU time. Without a time utility in the image, the CPU time is
+// the wall time of the run.
+func (p *WorkerPool) executeCode(containerID string, job Job, output *outputCollector) (runUsage, bool, error) {
+	language := job.Language
 	config, ok := GetLanguageConfig(language)
 	if !ok {
 		p.logger.WithFields(logrus.Fields{
 			"containerID": containerID[:12],
 			"language":    language,
 		}).Error(color.RedString("Unsupported language %s in container %s", language, containerID[:12]))
-		return "", false, fmt.Errorf("unsupported language: %s", language)
+		return runUsage{}, false, fmt.Errorf("unsupported language: %s", language)
+	}
+
+	version, err := ResolveVersion(language, job.Version)
+	if err != nil {
+		return runUsage{}, false, err
 	}
 
 	healthCheckCtx, healthCheckCancel := context.WithCancel(context.Background())
 	defer healthCheckCancel()
 
-	ctx, cancel := context.WithTimeout(healthCheckCtx, config.Timeout)
+	// The build and run steps set their own deadlines; ctx is cancelled when the container is removed
+	ctx, cancel := context.WithCancel(healthCheckCtx)
 	defer cancel()
+	outsurge := make(chan error, 1)
 
 	go func() {
 		ticker := time.NewTicker(100 * time.Millisecond)
@@ -194,10 +256,11 @@ func (p *WorkerPool) executeCode(containerID, language, code, stdin string) (str
 				}).Debug("health check context done")
 				return
 			case <-ticker.C:
-				if p.containerMgr.CheckResourceOutsurge(containerID) {
+				if err := p.containerMgr.CheckResourceOutsurge(containerID); err != nil {
 					p.logger.WithFields(logrus.Fields{
 						"containerID": containerID[:12],
 					}).Warn("resource limit exceeded, removing container")
+					outsurge <- err
 					go p.containerMgr.RemoveContainer(containerID)
 					cancel()
 					return
@@ -206,29 +269,77 @@ func (p *WorkerPool) executeCode(containerID, language, code, stdin string) (str
 		}
 	}()
 
-	var output bytes.Buffer
-	cmd := exec.CommandContext(ctx, "docker", append([]string{"exec", containerID}, config.Args(code, stdin)...)...)
-	cmd.Stdout = &output
-	cmd.Stderr = &output
+	if err := p.containerMgr.PrepareWorkspace(containerID, jobWorkspace, job.Files, job.Input); err != nil {
+		p.logger.WithFields(logrus.Fields{
+			"containerID": containerID[:12],
+			"error":       err,
+		}).Error(color.RedString("Failed to prepare workspace"))
+		return runUsage{}, false, err
+	}
+	defer p.containerMgr.KillWorkspace(containerID, jobWorkspace)
+
+	spec := buildSpec(job, version, jobWorkspace)
+	if buildLog, err := p.build(ctx, containerID, config, spec); err != nil {
+		p.logger.WithFields(logrus.Fields{
+			"containerID": containerID[:12],
+			"language":    language,
+			"version":     version.Name,
+			"error":       err,
+		}).Warn(color.YellowString("Build failed"))
+		fmt.Fprint(output.writer("stderr"), buildLog)
+		return runUsage{}, false, err
+	}
+
+	restore, err := p.containerMgr.applyMemoryLimit(containerID, job.MemoryLimit)
+	if err != nil {
+		return runUsage{}, false, err
+	}
+	defer restore()
+	oomBefore, _ := p.containerMgr.OOMKills(containerID)
+
+	runCtx, runCancel := context.WithTimeout(ctx, wallTimeout(job, config))
+	defer runCancel()
+	cmd := exec.CommandContext(runCtx, "docker", append(execArgs(containerID, spec.Workdir, false), config.RunArgs(spec)...)...)
+	cmd.Stdout = output.writer("stdout")
+	cmd.Stderr = output.writer("stderr")
 
 	start := time.Now()
-	err := cmd.Run()
+	err = cmd.Run()
 	duration := time.Since(start)
+	usage, measured := p.containerMgr.readUsage(containerID, jobWorkspace)
+	if !measured {
+		usage.CPU = duration
+	}
 
 	outputStr := output.String()
 	if len(outputStr) > 20 {
 		outputStr = outputStr[:20] + "..."
 	}
 
+	if err != nil {
+		select {
+		case surge := <-outsurge:
+			// The watchdog removed the container, taking its OOM counters with it
+			err = fmt.Errorf("%w: %w", surge, err)
+		default:
+			err = p.containerMgr.runError(runCtx, containerID, oomBefore, err)
+		}
+	}
+	// The time limit applies to the program's own CPU time, not to docker exec around it
+	if job.TimeLimit > 0 && usage.CPU > job.TimeLimit && !errors.Is(err, ErrMemoryLimitExceeded) && !errors.Is(err, ErrTimeLimitExceeded) {
+		err = fmt.Errorf("%w: used %dms of CPU", ErrTimeLimitExceeded, usage.CPU.Milliseconds())
+	}
+
 	if err != nil {
 		p.logger.WithFields(logrus.Fields{
 			"containerID": containerID[:12],
 			"language":    language,
+			"version":     version.Name,
 			"duration":    duration,
 			"output":      outputStr,
 			"error":       err,
 		}).Error(color.RedString("Execution error"))
-		return output.String(), false, fmt.Errorf("execution error: %w", err)
+		return usage, false, err
 	}
 
 	p.logger.WithFields(logrus.Fields{
@@ -237,33 +348,219 @@ func (p *WorkerPool) executeCode(containerID, language, code, stdin string) (str
 		"duration":    duration,
 	}).Debug(color.GreenString("Execution completed in container %s", containerID[:12]))
 
-	return output.String(), true, nil
+	return usage, true, nil
 }
 
-// ExecuteJob submits a job to the worker pool
-func (p *WorkerPool) ExecuteJob(language, code, stdin string) Result {
+// build compiles a prepared workspace under the language timeout. The compiler's output
+// is returned only when the build fails, so warnings never reach the program's output.
+func (p *WorkerPool) build(ctx context.Context, containerID string, config LanguageConfig, spec BuildSpec) (string, error) {
+	args := config.BuildArgs(spec)
+	if args == nil {
+		return "", nil
+	}
+	buildCtx, cancel := context.WithTimeout(ctx, config.Timeout)
+	defer cancel()
+
+	var buildLog bytes.Buffer
+	cmd := exec.CommandContext(buildCtx, "docker", append(execArgs(containerID, spec.Workdir, false), args...)...)
+	cmd.Stdout = &buildLog
+	cmd.Stderr = &buildLog
+	if err := cmd.Run(); err != nil {
+		return buildLog.String(), fmt.Errorf("%w: %w", ErrBuildFailed, err)
+	}
+	return "", nil
+}
+
+// runTimeout is the job's time limit, or the language timeout when it sets none
+func runTimeout(job Job, config LanguageConfig) time.Duration {
+	if job.TimeLimit > 0 {
+		return job.TimeLimit
+	}
+	return config.Timeout
+}
+
+// wallTimeout is how long a measured run may take on the clock. Its time limit is
+// checked against the CPU time it used, so the clock only stops runs that wait
+// instead of computing; it allows for docker exec starting the run.
+func wallTimeout(job Job, config LanguageConfig) time.Duration {
+	if job.TimeLimit > 0 {
+		return 2*job.TimeLimit + time.Second
+	}
+	return config.Timeout
+}
+
+// exitCode returns the exit status behind a run error, or -1 if the program
+// did not build or run to completion
+func exitCode(err error) int {
+	if err == nil {
+		return 0
+	}
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) && !errors.Is(err, ErrBuildFailed) {
+		return exitErr.ExitCode()
+	}
+	return -1
+}
+
+// ExecuteJob submits a job to the worker pool, rejecting it when the pool's queue is full
+func (p *WorkerPool) ExecuteJob(job Job) Result {
+	return p.submitJob(job, false)
+}
+
+// ExecuteJobWait submits a job to the worker pool like ExecuteJob, but waits for room
+// in a full queue instead of rejecting the job. Judging uses it, since a rejected test
+// is the engine's failure and not the participant's.
+func (p *WorkerPool) ExecuteJobWait(job Job) Result {
+	return p.submitJob(job, true)
+}
+
+// submitJob queues a job and waits for its result; a full queue rejects the job unless wait is set
+func (p *WorkerPool) submitJob(job Job, wait bool) Result {
+	language := job.Language
 	p.logger.WithFields(logrus.Fields{
 		"language": language,
+		"version":  job.Version,
 	}).Info("submitting job")
 
+	poolName, err := p.resolveJob(&job)
+	if err != nil {
+		return Result{Error: err}
+	}
+	queue := p.jobs[poolName]
+
 	result := make(chan Result, 1)
+	job.Result = result
 	select {
-	case p.jobs <- Job{Language: language, Code: code, Input: stdin, Result: result}:
-		return <-result
+	case queue <- job:
+		return p.awaitResult(result)
 	default:
+	}
+
+	if !wait {
 		p.logger.WithFields(logrus.Fields{
 			"language":    language,
+			"pool":        poolName,
 			"maxJobCount": p.maxJobCount,
 		}).Warn(color.YellowString("Job queue full, rejecting %s job (max: %d)", language, p.maxJobCount))
-		return Result{Error: fmt.Errorf("job queue full, max capacity: %d", p.maxJobCount)}
+		return Result{Error: fmt.Errorf("%w, max capacity: %d", ErrJobQueueFull, p.maxJobCount)}
+	}
+	p.logger.WithFields(logrus.Fields{
+		"language": language,
+		"pool":     poolName,
+	}).Debug("job queue full, waiting for room")
+	select {
+	case queue <- job:
+		return p.awaitResult(result)
+	case <-p.shutdownChan:
+		return errShuttingDown()
+	}
+}
+
+// awaitResult waits for a queued job's result. The queues are never closed, so senders
+// cannot race with shutdown; a job that no worker will pick up fails on shutdown instead.
+func (p *WorkerPool) awaitResult(result <-chan Result) Result {
+	select {
+	case r := <-result:
+		return r
+	case <-p.shutdownChan:
+		return errShuttingDown()
+	}
+}
+
+func errShuttingDown() Result {
+	return Result{Error: fmt.Errorf("%w: worker pool is shutting down", ErrJobQueueFull)}
+}
+
+// resolveJob fills in the version, flags and project of a job and returns the pool that runs it
+func (p *WorkerPool) resolveJob(job *Job) (string, error) {
+	requested, compileFlags, runFlags, err := ResolveFlags(job.Language, job.Version, job.Flags)
+	if err != nil {
+		return "", err
+	}
+	version, err := ResolveVersion(job.Language, requested)
+	if err != nil {
+		return "", err
+	}
+	job.Version = version.Name
+	job.compileFlags, job.runFlags = compileFlags, runFlags
+	if err := resolveProject(job); err != nil {
+		return "", err
+	}
+
+	poolName := p.containerMgr.poolForVersion(job.Language, version)
+	if !p.containerMgr.HasPool(poolName) {
+		return "", fmt.Errorf("%s@%s is not available: container pool %s is not configured", job.Language, version.Name, poolName)
 	}
+
+	if job.Interactor != nil {
+		interactor := *job.Interactor
+		if interactor.Interactor != nil {
+			return "", fmt.Errorf("an interactor cannot have its own interactor")
+		}
+		interactorPool, err := p.resolveJob(&interactor)
+		if err != nil {
+			return "", fmt.Errorf("interactor: %w", err)
+		}
+		// The interactor runs in the program's container, so it needs the same toolchains
+		if p.containerMgr.pools[interactorPool].Image != p.containerMgr.pools[poolName].Image {
+			return "", fmt.Errorf("interactor %s@%s cannot run alongside %s@%s: pools %s and %s use different images",
+				interactor.Language, interactor.Version, job.Language, job.Version, interactorPool, poolName)
+		}
+		job.Interactor = &interactor
+	}
+	return poolName, nil
+}
+
+// buildSpec assembles the build spec of a resolved job for a workspace
+func buildSpec(job Job, version LanguageVersion, workspace string) BuildSpec {
+	return BuildSpec{
+		Version:      version,
+		CompileFlags: job.compileFlags,
+		RunFlags:     job.runFlags,
+		Workdir:      workspacePath(workspace),
+		Entrypoint:   job.Entrypoint,
+		EntryClass:   entryClass(job.Entrypoint, fileContent(job.Files, job.Entrypoint)),
+		Args:         job.Args,
+	}
+}
+
+// SetJudgeParallelism caps how many tests of one submission may run at once
+func (p *WorkerPool) SetJudgeParallelism(n int) {
+	if n < 1 {
+		n = 1
+	}
+	p.parallelism = n
+}
+
+// JudgeParallelism returns how many tests of one submission may run at once for a
+// language version: the configured cap, bounded by the size of the pool that runs it
+func (p *WorkerPool) JudgeParallelism(language, version string) int {
+	resolved, err := ResolveVersion(language, version)
+	if err != nil {
+		return 1
+	}
+	pool, ok := p.containerMgr.pools[p.containerMgr.poolForVersion(language, resolved)]
+	if !ok {
+		return 1
+	}
+	return max(1, min(p.parallelism, pool.Size))
+}
+
+// Languages lists the language versions that the configured pools can run
+func (p *WorkerPool) Languages() []LanguageInfo {
+	return listLanguages(p.containerMgr.poolForVersion, p.containerMgr.HasPool)
+}
+
+// Pools returns the container pools served by this worker pool
+func (p *WorkerPool) Pools() []PoolConfig {
+	return p.containerMgr.Pools()
 }
 
 // Shutdown gracefully stops the worker pool
 func (p *WorkerPool) Shutdown() {
 	p.logger.Info("shutting down worker pool")
+	// Workers stop on shutdownChan; the queues stay open so that late senders cannot panic
 	close(p.shutdownChan)
-	close(p.jobs)
 	p.containerMgr.Shutdown()
 	p.wg.Wait()
 	p.logger.Info("worker pool shutdown complete")
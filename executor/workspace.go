@@ -0,0 +1,232 @@
+package executor
+
+import (
+	"archive/tar"
+	"bytes"
+	"context"
+	"fmt"
+	"os/exec"
+	"path"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/docker/docker/api/types/container"
+)
+
+// workspaceRoot is the writable directory inside worker containers that holds job workspaces
+const workspaceRoot = "/app/temp"
+
+// jobWorkspace is the workspace, relative to workspaceRoot, used for the submitted program
+const jobWorkspace = "job"
+
+// inputFile is the name of the stdin file at the root of a workspace
+const inputFile = "input.txt"
+
+// File is a source file placed in a job workspace, named relative to the project root
+type File struct {
+	Name    string
+	Content string
+}
+
+// safePath restricts workspace file names to relative paths that need no shell quoting
+var safePath = regexp.MustCompile(`^[A-Za-z0-9_][A-Za-z0-9_.+-]*(/[A-Za-z0-9_][A-Za-z0-9_.+-]*)*$`)
+
+// ValidateFileName checks that a project file name is a safe relative path
+func ValidateFileName(name string) error {
+	if !safePath.MatchString(name) || path.Clean(name) != name {
+		return fmt.Errorf("invalid file name: %q", name)
+	}
+	for _, part := range strings.Split(name, "/") {
+		if part == ".." || part == "." {
+			return fmt.Errorf("invalid file name: %q", name)
+		}
+	}
+	return nil
+}
+
+// safeArg restricts program arguments to characters that need no shell quoting
+var safeArg = regexp.MustCompile(`^[A-Za-z0-9_.+=:,/-]+$`)
+
+// ValidateArg checks that a program argument can be passed without quoting
+func ValidateArg(arg string) error {
+	if !safeArg.MatchString(arg) {
+		return fmt.Errorf("invalid program argument: %q", arg)
+	}
+	return nil
+}
+
+// resolveProject turns a job into a project: single-file code becomes the language
+// source file, and the entrypoint is checked against the project files
+func resolveProject(job *Job) error {
+	config, ok := languageConfigs[job.Language]
+	if !ok {
+		return fmt.Errorf("unsupported language: %s", job.Language)
+	}
+
+	for _, arg := range job.Args {
+		if err := ValidateArg(arg); err != nil {
+			return err
+		}
+	}
+
+	if len(job.Files) == 0 {
+		job.Files = []File{{Name: config.SourceFile, Content: job.Code}}
+		job.Entrypoint = config.SourceFile
+		return nil
+	}
+
+	seen := make(map[string]bool, len(job.Files))
+	for _, f := range job.Files {
+		if err := ValidateFileName(f.Name); err != nil {
+			return err
+		}
+		if seen[f.Name] {
+			return fmt.Errorf("duplicate file: %s", f.Name)
+		}
+		seen[f.Name] = true
+	}
+
+	if job.Entrypoint == "" && seen[config.SourceFile] {
+		job.Entrypoint = config.SourceFile
+	}
+	if job.Entrypoint == "" {
+		if config.UsesEntrypoint {
+			return fmt.Errorf("%s projects require an entrypoint", job.Language)
+		}
+		return nil
+	}
+	if err := ValidateFileName(job.Entrypoint); err != nil {
+		return err
+	}
+	if !seen[job.Entrypoint] {
+		return fmt.Errorf("entrypoint %s is not part of the project", job.Entrypoint)
+	}
+	return nil
+}
+
+// fileContent returns the content of a named project file, or an empty string
+func fileContent(files []File, name string) string {
+	for _, f := range files {
+		if f.Name == name {
+			return f.Content
+		}
+	}
+	return ""
+}
+
+// privateRoot holds the workspaces of judge programs that read secret test data while
+// the participant's program runs in the same container. The image makes it root's with
+// mode 0700, and those workspaces are written and run as root, out of the program's reach.
+const privateRoot = "/app/judge"
+
+// workspacePath returns the absolute path of a workspace inside the container
+func workspacePath(workspace string) string {
+	if workspace == interactorWorkspace {
+		return privateRoot + "/" + workspace
+	}
+	return workspaceRoot + "/" + workspace
+}
+
+// execArgs returns the docker arguments that run a command in a container for a
+// workspace directory: as root for private workspaces, as the image's user otherwise
+func execArgs(containerID, workdir string, stdin bool) []string {
+	args := []string{"exec"}
+	if stdin {
+		args = append(args, "-i")
+	}
+	if strings.HasPrefix(workdir, privateRoot+"/") {
+		args = append(args, "-u", "root")
+	}
+	return append(args, containerID)
+}
+
+// buildArchive packs files into a tar stream; names are relative to the workspace's
+// root. Private files are readable by their owner, root, alone.
+func buildArchive(files []File, private bool) (*bytes.Buffer, error) {
+	dirMode, fileMode := int64(0770), int64(0660)
+	if private {
+		dirMode, fileMode = 0700, 0600
+	}
+	var buf bytes.Buffer
+	tw := tar.NewWriter(&buf)
+	dirs := make(map[string]bool)
+
+	for _, f := range files {
+		// Parent directories must precede their files in the archive
+		for dir := path.Dir(f.Name); dir != "."; dir = path.Dir(dir) {
+			if dirs[dir] {
+				break
+			}
+			dirs[dir] = true
+		}
+	}
+	for _, dir := range sortedKeys(dirs) {
+		if err := tw.WriteHeader(&tar.Header{Name: dir + "/", Mode: dirMode, Typeflag: tar.TypeDir, ModTime: time.Now()}); err != nil {
+			return nil, err
+		}
+	}
+
+	for _, f := range files {
+		hdr := &tar.Header{
+			Name:    f.Name,
+			Mode:    fileMode,
+			Size:    int64(len(f.Content)),
+			ModTime: time.Now(),
+		}
+		if err := tw.WriteHeader(hdr); err != nil {
+			return nil, err
+		}
+		if _, err := tw.Write([]byte(f.Content)); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := tw.Close(); err != nil {
+		return nil, err
+	}
+	return &buf, nil
+}
+
+// PrepareWorkspace replaces a workspace in the container with the given files.
+// Sources go under <workspace>/src and stdin is written to <workspace>/input.txt.
+func (cm *ContainerManager) PrepareWorkspace(containerID, workspace string, sources []File, stdin string) error {
+	files := make([]File, 0, len(sources)+1)
+	files = append(files, File{Name: workspace + "/" + inputFile, Content: stdin})
+	for _, f := range sources {
+		if err := ValidateFileName(f.Name); err != nil {
+			return err
+		}
+		files = append(files, File{Name: workspace + "/src/" + f.Name, Content: f.Content})
+	}
+
+	dir := workspacePath(workspace)
+	private := path.Dir(dir) == privateRoot
+	archive, err := buildArchive(files, private)
+	if err != nil {
+		return fmt.Errorf("failed to pack workspace: %v", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	if out, err := exec.CommandContext(ctx, "docker", append(execArgs(containerID, dir, false), "rm", "-rf", dir)...).CombinedOutput(); err != nil {
+		return fmt.Errorf("failed to clear workspace: %v: %s", err, out)
+	}
+
+	// Only workspaces the program may use take the ownership of the image's user
+	if err := cm.dockerClient.CopyToContainer(ctx, containerID, path.Dir(dir), archive, container.CopyToContainerOptions{CopyUIDGID: !private}); err != nil {
+		return fmt.Errorf("failed to copy workspace: %v", err)
+	}
+	return nil
+}
+
+// KillWorkspace kills every process in the container that was started from a workspace
+func (cm *ContainerManager) KillWorkspace(containerID, workspace string) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	// pkill exits non-zero when nothing matched, which is the common case
+	dir := workspacePath(workspace)
+	exec.CommandContext(ctx, "docker", append(execArgs(containerID, dir, false), "pkill", "-9", "-f", dir)...).Run()
+}
@@ -1,10 +1,27 @@
 package model
 
+import "time"
+
+// SourceFile is one file of a multi-file submission
+type SourceFile struct {
+	Name    string `json:"name"`
+	Content string `json:"content"`
+}
+
+// ProjectFiles carries a multi-file submission as explicit files or a base64 archive
+type ProjectFiles struct {
+	Files      []SourceFile `json:"files,omitempty"`
+	Archive    string       `json:"archive,omitempty"` // base64 tar, tar.gz or zip
+	Entrypoint string       `json:"entrypoint,omitempty"`
+}
+
 // ExecutionRequest represents the request structure for code execution
 type CompilerRequest struct {
-	Code     string `json:"code" binding:"required"`
-	Language string `json:"language" binding:"required"`
-	Input    string `json:"input,omitempty"`
+	Code     string   `json:"code" binding:"required"`
+	Language string   `json:"language" binding:"required"`
+	Input    string   `json:"input,omitempty"`
+	Flags    []string `json:"flags,omitempty"`
+	ProjectFiles
 }
 
 // ExecutionResponse represents the response structure for executed code
@@ -24,47 +41,476 @@ type ProblemExecutionResponse struct {
 }
 
 type ProblemExecutionRequest struct {
-	Code      string `json:"code" binding:"required"`
-	Language  string `json:"language" binding:"required"`
-	ProblemID string `json:"problem_id"`
-	Input     string `json:"input,omitempty"`
+	Code      string   `json:"code" binding:"required"`
+	Language  string   `json:"language" binding:"required"`
+	ProblemID string   `json:"problem_id"`
+	Input     string   `json:"input,omitempty"`
+	Flags     []string `json:"flags,omitempty"`
+	JudgeMode string   `json:"judge_mode,omitempty"` // overrides the problem's judging mode
+	Submitter string   `json:"submitter,omitempty"`  // who submitted, as the calling platform identifies them
+	ProjectFiles
 }
 
 type TestCase struct {
 	Name           string `json:"name"`
 	Input          string `json:"input"`
 	ExpectedOutput string `json:"expected_output"`
+	// Hidden tests are left out of problem listings and their results are redacted;
+	// the others are samples shown to participants
+	Hidden bool `json:"hidden,omitempty"`
+	// Generated tests were materialised from the problem's TestScript
+	Generated bool `json:"generated,omitempty"`
 }
 
 type Problem struct {
-	ID          string     `json:"id"`
-	Title       string     `json:"title"`
-	Description string     `json:"description"`
-	InputFormat string     `json:"input_format"`
-	Constraints string     `json:"constraints"`
-	TestCases   []TestCase `json:"test_cases"`
+	ID               string                     `json:"id"`
+	Title            string                     `json:"title"`
+	Description      string                     `json:"description"`
+	InputFormat      string                     `json:"input_format"`
+	Constraints      string                     `json:"constraints"`
+	TimeLimit        int                        `json:"time_limit_ms,omitempty"`     // per test, excluding the build; zero uses the language timeout
+	MemoryLimit      int                        `json:"memory_limit_mb,omitempty"`   // per test; zero uses the container pool limit
+	LimitMultipliers map[string]LimitMultiplier `json:"limit_multipliers,omitempty"` // per-language scaling of the limits, for slower languages
+	DefaultFlags     map[string][]string        `json:"default_flags,omitempty"`     // per-language flags used when a submission sets none
+	Comparator       Comparator                 `json:"comparator"`                  // built-in output comparison; ignored when Checker is set
+	Function         *Function                  `json:"function,omitempty"`          // the function participants write, for function-signature problems
+	Checker          *Checker                   `json:"-"`                           // judges outputs when a test has more than one valid answer
+	Interactor       *Interactor                `json:"-"`                           // talks to the program instead of feeding it the input; replaces Comparator and Checker
+	Validator        *Validator                 `json:"-"`                           // checks that test inputs meet the constraints
+	Solution         *Solution                  `json:"-"`                           // reference solution that produces the expected outputs
+	Generators       []Generator                `json:"-"`                           // programs that write test inputs
+	TestScript       []GeneratedTest            `json:"-"`                           // generator runs that materialise tests after the written ones
+	TestCases        []TestCase                 `json:"test_cases"`
+	Subtasks         []Subtask                  `json:"subtasks,omitempty"`   // IOI-style groups of tests with scores
+	JudgeMode        string                     `json:"judge_mode,omitempty"` // one of the JudgeMode values; empty means full
+	Version          int                        `json:"version,omitempty"`    // immutable version the store assigned to this content
+	Archived         bool                       `json:"archived,omitempty"`   // archived problems are unlisted and take no submissions
+	Draft            bool                       `json:"draft,omitempty"`      // unpublished: unlisted and closed to submissions
+	// PendingValidation marks a problem published in its folder that is held back as a
+	// draft until its validator accepts the test inputs
+	PendingValidation bool `json:"pending_validation,omitempty"`
+}
+
+// ProblemDocument is a complete problem, judge programs included, as admins write it
+// and as problem versions are kept
+type ProblemDocument struct {
+	Problem
+	Checker    *Checker        `json:"checker,omitempty"`
+	Interactor *Interactor     `json:"interactor,omitempty"`
+	Validator  *Validator      `json:"validator,omitempty"`
+	Solution   *Solution       `json:"solution,omitempty"`
+	Generators []Generator     `json:"generators,omitempty"`
+	TestScript []GeneratedTest `json:"test_script,omitempty"`
+}
+
+// Document returns the problem with its judge programs in serialisable form
+func (p Problem) Document() ProblemDocument {
+	return ProblemDocument{
+		Problem:    p,
+		Checker:    p.Checker,
+		Interactor: p.Interactor,
+		Validator:  p.Validator,
+		Solution:   p.Solution,
+		Generators: p.Generators,
+		TestScript: p.TestScript,
+	}
+}
+
+// ToProblem returns the problem a document describes
+func (d ProblemDocument) ToProblem() Problem {
+	p := d.Problem
+	p.Checker, p.Interactor, p.Validator, p.Solution = d.Checker, d.Interactor, d.Validator, d.Solution
+	p.Generators, p.TestScript = d.Generators, d.TestScript
+	return p
+}
+
+// Judging modes
+const (
+	JudgeModeFull = "full" // run every test
+	JudgeModeICPC = "icpc" // stop at the first test that is not accepted and skip the rest
+)
+
+// Subtask scoring rules
+const (
+	ScoringAll = "all" // the full score if every test passes, otherwise nothing
+	ScoringMin = "min" // the score times the lowest test points
+	ScoringSum = "sum" // the score shared equally between the tests
+)
+
+// Subtask groups test cases that are scored together
+type Subtask struct {
+	Name      string   `json:"name"`
+	Score     float64  `json:"score"`
+	Scoring   string   `json:"scoring,omitempty"`    // one of the Scoring rules; empty means all
+	Tests     []int    `json:"tests"`                // 1-based test case indices; a test may be shared
+	DependsOn []string `json:"depends_on,omitempty"` // earlier subtasks that must get full score, or this one is skipped
+}
+
+// SubtaskResult reports the score of one subtask
+type SubtaskResult struct {
+	Name     string  `json:"name"`
+	Status   string  `json:"status"` // AC, the first failing test verdict, or SKIPPED
+	Score    float64 `json:"score"`
+	MaxScore float64 `json:"max_score"`
+	Tests    []int   `json:"tests"`
+}
+
+// Public returns a copy of the problem without its hidden test cases
+func (p Problem) Public() Problem {
+	samples := make([]TestCase, 0, len(p.TestCases))
+	for _, tc := range p.TestCases {
+		if !tc.Hidden {
+			samples = append(samples, tc)
+		}
+	}
+	p.TestCases = samples
+	return p
+}
+
+// Built-in comparison modes for problem outputs
+const (
+	CompareExact           = "exact"            // whole output, ignoring surrounding whitespace
+	CompareTokens          = "tokens"           // whitespace-separated tokens
+	CompareFloat           = "float"            // tokens, numbers within an absolute or relative epsilon
+	CompareCaseInsensitive = "case-insensitive" // tokens, ignoring letter case
+	CompareUnorderedLines  = "unordered-lines"  // lines in any order, ignoring surrounding whitespace
+	CompareValues          = "values"           // JSON values; integers exactly, other numbers within epsilon
+)
+
+// Function declares the function a function-signature problem asks for. The engine
+// wraps a submission in a harness that reads the arguments of a test, a JSON array,
+// from its input, calls the function, and prints the return value as JSON.
+type Function struct {
+	Name    string      `json:"name"`
+	Params  []Parameter `json:"params"`
+	Returns string      `json:"returns"` // one of the Type values
+}
+
+// Parameter is one argument of a function-signature problem
+type Parameter struct {
+	Name string `json:"name"`
+	Type string `json:"type"` // one of the Type values
+}
+
+// Value types of function-signature problems; each may be followed by one or more
+// "[]" for (nested) lists, e.g. "int[][]"
+const (
+	TypeInt    = "int"    // 32-bit signed integer
+	TypeLong   = "long"   // 64-bit signed integer
+	TypeDouble = "double" // 64-bit float
+	TypeBool   = "bool"
+	TypeString = "string"
+)
+
+// Comparator selects how a participant's output is compared with the expected answer
+type Comparator struct {
+	Mode    string  `json:"mode,omitempty"`    // one of the Compare modes; empty means exact
+	Epsilon float64 `json:"epsilon,omitempty"` // tolerance for float comparison; defaults to 1e-6
+	// PresentationError reports PE instead of WA when the output matches token-wise but not byte-wise
+	PresentationError bool `json:"presentation_error,omitempty"`
+}
+
+// LimitMultiplier scales a problem's limits for one language; zero factors leave a limit unchanged
+type LimitMultiplier struct {
+	Time   float64 `json:"time,omitempty"`
+	Memory float64 `json:"memory,omitempty"`
+}
+
+// Checker is a testlib-compatible program that judges a participant's output.
+// It runs as `checker input.txt output.txt answer.txt` and reports through its
+// exit code: 0 accepted, 1 wrong answer, 2 presentation error, 7 partial points (a
+// "points <share>" comment, from 0 to 1), anything else a judge failure.
+type Checker struct {
+	Language string `json:"language"`
+	Source   string `json:"source"`
+}
+
+// Interactor is a testlib-compatible program that converses with a participant's
+// program over its stdin and stdout. It runs as `interactor input.txt output.txt` with
+// the test input in input.txt and reports through the same exit codes as a Checker.
+type Interactor struct {
+	Language string `json:"language"`
+	Source   string `json:"source"`
+}
+
+// Validator is a testlib-compatible program that checks a test input against the
+// problem's constraints. It reads the input on stdin and exits 0 when it is valid.
+type Validator struct {
+	Language string `json:"language"`
+	Source   string `json:"source"`
 }
 
+// Solution is a problem's reference solution, run on every test input to produce the
+// expected outputs. Whatever it writes, stderr included, becomes the output.
+type Solution struct {
+	Language string `json:"language"`
+	Source   string `json:"source"`
+}
+
+// Generator is a program that writes a test input to stdout. It runs as
+// `generator ARGS... SEED`, so testlib's registerGen seeds its random numbers from them.
+type Generator struct {
+	Name     string `json:"name"`
+	Language string `json:"language"`
+	Source   string `json:"source"`
+}
+
+// GeneratedTest is one line of a problem's test script: the input is what Generator
+// prints for Args and Seed. Generated tests are hidden unless marked Sample.
+type GeneratedTest struct {
+	Generator string   `json:"generator"`
+	Args      []string `json:"args,omitempty"`
+	Seed      int64    `json:"seed"`
+	Name      string   `json:"name,omitempty"` // defaults to the generator, args and seed
+	Sample    bool     `json:"sample,omitempty"`
+}
+
+// Judge verdicts for test cases and submissions
+const (
+	VerdictAccepted          = "AC"
+	VerdictWrongAnswer       = "WA"
+	VerdictPresentationError = "PE"
+	VerdictTimeLimit         = "TLE"
+	VerdictMemoryLimit       = "MLE"
+	VerdictCompilationError  = "CE"
+	VerdictRuntimeError      = "RE"
+	VerdictJudgeFailure      = "FAIL"
+	VerdictPartial           = "PC"      // checker awarded part of the test's points
+	VerdictSkipped           = "SKIPPED" // not run, after an earlier failure or a failed prerequisite subtask
+)
+
 type TestCaseResult struct {
+	Name          string      `json:"name"`
+	Status        string      `json:"status"`
+	Input         string      `json:"input,omitempty"`
+	Expected      string      `json:"expected,omitempty"`
+	Output        string      `json:"output,omitempty"`
+	Error         string      `json:"error,omitempty"`
+	Message       string      `json:"message,omitempty"`    // checker comment on the output
+	Diff          *OutputDiff `json:"diff,omitempty"`       // where the output departs from the expected one, for WA and PE
+	Transcript    string      `json:"transcript,omitempty"` // exchange with the interactor, truncated
+	Points        float64     `json:"points"`               // share of the test earned, from 0 to 1
+	ExecutionTime string      `json:"execution_time,omitempty"`
+	Memory        int64       `json:"memory_kb,omitempty"` // peak memory of the run, when measured
+	Hidden        bool        `json:"hidden,omitempty"`    // the test is hidden and this result is redacted
+}
+
+// Redacted keeps only the status, time and memory of a result, for hidden tests.
+// Points stay so that subtask scores can be computed.
+func (r TestCaseResult) Redacted() TestCaseResult {
+	return TestCaseResult{
+		Name:          r.Name,
+		Status:        r.Status,
+		Points:        r.Points,
+		ExecutionTime: r.ExecutionTime,
+		Memory:        r.Memory,
+		Hidden:        true,
+	}
+}
+
+// OutputDiff locates the first mismatch between the expected and actual output.
+// Positions are 1-based and refer to the trimmed Expected and Output of the test result.
+type OutputDiff struct {
+	Line     int    `json:"line"`
+	Column   int    `json:"column"`
+	Expected string `json:"expected_line"`
+	Actual   string `json:"actual_line"`
+	Unified  string `json:"unified"` // bounded unified diff around the first mismatch
+}
+
+type ProblemSubmissionRequest struct {
+	ProblemID string   `json:"problem_id"`
+	Code      string   `json:"code"`
+	Language  string   `json:"language"`
+	Flags     []string `json:"flags,omitempty"`
+	JudgeMode string   `json:"judge_mode,omitempty"` // overrides the problem's judging mode
+	Submitter string   `json:"submitter,omitempty"`  // who submitted, as the calling platform identifies them
+	ProjectFiles
+}
+
+type JudgeResponse struct {
+	SubmissionID   string           `json:"submission_id,omitempty"` // the submission's record, for later lookups
+	ProblemID      string           `json:"problem_id"`
+	Verdict        string           `json:"verdict"`
+	Summary        string           `json:"summary"`                   // e.g. "Accepted" or "WA on test 7"
+	JudgeMode      string           `json:"judge_mode,omitempty"`      // mode the submission was judged in
+	Version        string           `json:"version,omitempty"`         // exact language version judged, e.g. python@3.12
+	ProblemVersion int              `json:"problem_version,omitempty"` // version of the problem judged against
+	Results        []TestCaseResult `json:"results"`
+	Subtasks       []SubtaskResult  `json:"subtasks,omitempty"`
+	Score          float64          `json:"score,omitempty"`     // total subtask score
+	MaxScore       float64          `json:"max_score,omitempty"` // sum of the subtask scores
+}
+
+// OutputGenerationResponse reports a run of the reference solution over a problem's tests
+type OutputGenerationResponse struct {
+	ProblemID      string            `json:"problem_id"`
+	ProblemVersion int               `json:"problem_version"` // version holding the generated outputs
+	Updated        int               `json:"updated"`         // tests whose expected output changed
+	Tests          []GeneratedOutput `json:"tests"`
+}
+
+// GeneratedOutput reports the reference solution on one test. A test whose run did not
+// succeed is flagged and keeps its previous expected output.
+type GeneratedOutput struct {
 	Name          string `json:"name"`
-	Status        string `json:"status"`
-	Input         string `json:"input,omitempty"`
-	Expected      string `json:"expected,omitempty"`
-	Output        string `json:"output,omitempty"`
+	Status        string `json:"status"` // AC when the run succeeded, otherwise CE, TLE, MLE or RE
+	Flagged       bool   `json:"flagged,omitempty"`
+	Changed       bool   `json:"changed,omitempty"`
 	Error         string `json:"error,omitempty"`
 	ExecutionTime string `json:"execution_time,omitempty"`
 }
 
-type ProblemSubmissionRequest struct {
-	ProblemID string `json:"problem_id"`
-	Code      string `json:"code"`
-	Language  string `json:"language"`
+// TestGenerationResponse reports materialising a problem's test script
+type TestGenerationResponse struct {
+	ProblemID      string                    `json:"problem_id"`
+	ProblemVersion int                       `json:"problem_version"`
+	Tests          []GeneratedInput          `json:"tests"`
+	Outputs        *OutputGenerationResponse `json:"outputs,omitempty"`    // expected outputs, when the problem has a reference solution
+	Validation     *InputValidationReport    `json:"validation,omitempty"` // the validator's verdict on every input
 }
 
-type JudgeResponse struct {
-	ProblemID string           `json:"problem_id"`
-	Verdict   string           `json:"verdict"`
-	Results   []TestCaseResult `json:"results"`
+// GeneratedInput reports one line of a test script
+type GeneratedInput struct {
+	Name   string `json:"name"`
+	Hash   string `json:"hash"`   // identifies the generator, args and seed
+	Cached bool   `json:"cached"` // the input came from the cache rather than a new run
+	Size   int    `json:"size"`
+}
+
+// InputValidationReport reports a problem's validator run over its test inputs
+type InputValidationReport struct {
+	ProblemID string            `json:"problem_id"`
+	Valid     bool              `json:"valid"`           // every input passed, or the problem has no validator
+	Error     string            `json:"error,omitempty"` // the validator itself failed, e.g. to compile
+	Tests     []InputValidation `json:"tests,omitempty"`
+}
+
+// InputValidation reports the validator's verdict on one test input
+type InputValidation struct {
+	Name    string `json:"name"`
+	Valid   bool   `json:"valid"`
+	Message string `json:"message,omitempty"` // why the validator rejected the input
+}
+
+// StressProgram is one of the programs a stress test runs
+type StressProgram struct {
+	Language string   `json:"language"`
+	Code     string   `json:"code"`
+	Flags    []string `json:"flags,omitempty"`
+}
+
+// StressRequest asks to run a solution and a brute force on generated inputs until
+// their outputs differ
+type StressRequest struct {
+	Solution    StressProgram `json:"solution"`
+	BruteForce  StressProgram `json:"brute_force"`
+	Generator   StressProgram `json:"generator"`
+	Args        []string      `json:"args,omitempty"`          // generator arguments; the seed is passed after them
+	StartSeed   int64         `json:"start_seed,omitempty"`    // first seed tried; defaults to 1
+	MaxRuns     int           `json:"max_runs,omitempty"`      // seeds to try before giving up; defaults to 100
+	TimeLimitMs int           `json:"time_limit_ms,omitempty"` // per run, for both solutions
+	Comparator  Comparator    `json:"comparator"`
+}
+
+// StressResponse reports a stress test: the first input on which the solution's
+// output differs from the brute force's, if any
+type StressResponse struct {
+	Found    bool        `json:"found"`
+	Runs     int         `json:"runs"` // seeds tried
+	Seed     int64       `json:"seed,omitempty"`
+	Input    string      `json:"input,omitempty"`
+	Output   string      `json:"output,omitempty"`   // the solution's output
+	Expected string      `json:"expected,omitempty"` // the brute force's output
+	Status   string      `json:"status,omitempty"`   // the solution's verdict, e.g. WA or TLE
+	Diff     *OutputDiff `json:"diff,omitempty"`
+}
+
+// JudgeEvent reports progress while a submission is judged
+type JudgeEvent struct {
+	Type   string          `json:"type"` // "test_started", "output" or "test_finished"
+	Test   int             `json:"test"` // 1-based test index
+	Total  int             `json:"total"`
+	Name   string          `json:"name,omitempty"`
+	Stream string          `json:"stream,omitempty"` // "stdout" or "stderr" for output events
+	Data   string          `json:"data,omitempty"`
+	Result *TestCaseResult `json:"result,omitempty"`
+}
+
+// Submission states, in the order a submission goes through them
+const (
+	SubmissionQueued   = "queued"
+	SubmissionRunning  = "running"
+	SubmissionFinished = "finished"
+	SubmissionFailed   = "failed" // judging could not start, e.g. the code failed sanitising
+)
+
+// Submission is a submission judged in the background, as clients poll it
+type Submission struct {
+	ID             string     `json:"id"`
+	Submitter      string     `json:"submitter,omitempty"`
+	ProblemID      string     `json:"problem_id"`
+	ProblemVersion int        `json:"problem_version,omitempty"` // version judged against, once judging finished
+	Language       string     `json:"language"`                  // as submitted; the result names the exact version judged
+	Code           string     `json:"code,omitempty"`
+	Flags          []string   `json:"flags,omitempty"`
+	Status         string     `json:"status"` // one of the Submission states
+	Created        time.Time  `json:"created"`
+	Started        *time.Time `json:"started,omitempty"`
+	Finished       *time.Time `json:"finished,omitempty"`
+	ProjectFiles
+	// Tests reports each test while the submission runs; tests not yet finished carry
+	// the queued or running state. The result replaces it once judging finishes.
+	Tests     []TestCaseResult `json:"tests,omitempty"`
+	Completed int              `json:"completed"` // tests finished so far
+	Result    *JudgeResponse   `json:"result,omitempty"`
+	Error     string           `json:"error,omitempty"` // why judging failed
+}
+
+// Summary returns the submission as history listings show it
+func (s Submission) Summary() SubmissionSummary {
+	summary := SubmissionSummary{
+		ID:             s.ID,
+		Submitter:      s.Submitter,
+		ProblemID:      s.ProblemID,
+		ProblemVersion: s.ProblemVersion,
+		Language:       s.Language,
+		Status:         s.Status,
+		Created:        s.Created,
+		Finished:       s.Finished,
+	}
+	if s.Result != nil {
+		if s.Result.Version != "" {
+			summary.Language = s.Result.Version
+		}
+		summary.Verdict = s.Result.Verdict
+		summary.Summary = s.Result.Summary
+		summary.Score = s.Result.Score
+		summary.MaxScore = s.Result.MaxScore
+	}
+	return summary
+}
+
+// SubmissionSummary is a submission in history listings, without its code and results
+type SubmissionSummary struct {
+	ID             string     `json:"id"`
+	Submitter      string     `json:"submitter,omitempty"`
+	ProblemID      string     `json:"problem_id"`
+	ProblemVersion int        `json:"problem_version,omitempty"`
+	Language       string     `json:"language"`
+	Status         string     `json:"status"`
+	Verdict        string     `json:"verdict,omitempty"`
+	Summary        string     `json:"summary,omitempty"`
+	Score          float64    `json:"score,omitempty"`
+	MaxScore       float64    `json:"max_score,omitempty"`
+	Created        time.Time  `json:"created"`
+	Finished       *time.Time `json:"finished,omitempty"`
+}
+
+// SubmissionHistory is one page of a history listing, newest first
+type SubmissionHistory struct {
+	Submissions []SubmissionSummary `json:"submissions"`
+	Next        string              `json:"next,omitempty"` // pass as before to get the next page
 }
 
 // ContainerStats represents the overall JSON structure.
@@ -20,14 +20,14 @@ func HandleCompilerRequest(msg *nats.Msg, nc *nats.Conn, workerPool *executor.Wo
 
 	compilerService := service.NewCompilerService(workerPool)
 
-	res, err := compilerService.Compile(req.Code, req.Language, req.Input)
+	res, err := compilerService.Compile(req.Code, req.Language, req.Input, req.Flags, req.ProjectFiles)
 	if err != nil {
 		log.Printf("Failed to compile code: %v", err)
 		return
 	}
 
 	// Send response back to API Gateway
-	resData, _ := json.Marshal(res)
+	resData, _ := json.Marshal(res.CompileResponse)
 	nc.Publish(msg.Reply, resData)
 }
 
@@ -41,7 +41,15 @@ func HandleProblemRunRequest(msg *nats.Msg, nc *nats.Conn, workerPool *executor.
 	compilerService := service.NewCompilerService(workerPool)
 
 	if req.ProblemID != "" {
-		res, err := compilerService.JudgeProblem(req.Code, req.Language, req.ProblemID)
+		res, err := compilerService.JudgeSubmission(model.ProblemSubmissionRequest{
+			ProblemID:    req.ProblemID,
+			Code:         req.Code,
+			Language:     req.Language,
+			Flags:        req.Flags,
+			JudgeMode:    req.JudgeMode,
+			Submitter:    req.Submitter,
+			ProjectFiles: req.ProjectFiles,
+		}, nil)
 		if err != nil {
 			log.Printf("Failed to judge code: %v", err)
 			return
@@ -51,13 +59,13 @@ func HandleProblemRunRequest(msg *nats.Msg, nc *nats.Conn, workerPool *executor.
 		return
 	}
 
-	res, err := compilerService.ExecuteProblemCode(req.Code, req.Language)
+	res, err := compilerService.ExecuteProblemCode(req.Code, req.Language, req.Flags, req.ProjectFiles)
 	if err != nil {
 		log.Printf("Failed to compile code: %v", err)
 		return
 	}
 
 	// Send response back to API Gateway
-	resData, _ := json.Marshal(res)
+	resData, _ := json.Marshal(res.CompileResponse)
 	nc.Publish(msg.Reply, resData)
 }
@@ -0,0 +1,158 @@
+package problems
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"xcodeengine/model"
+)
+
+var (
+	ErrProblemNotFound = errors.New("problem not found")
+	ErrReadOnly        = errors.New("built-in problems cannot be edited; set PROBLEMS_DIR")
+	ErrTestNotFound    = errors.New("test not found")
+)
+
+// Create adds a problem and returns it with its first version
+func (s *Store) Create(problem model.Problem) (*model.Problem, error) {
+	s.writes.Lock()
+	defer s.writes.Unlock()
+	if s.dir == "" {
+		return nil, ErrReadOnly
+	}
+	if _, ok := s.Get(problem.ID); ok {
+		return nil, fmt.Errorf("%w: %s", ErrProblemExists, problem.ID)
+	}
+	return s.save(problem, "")
+}
+
+// Update replaces a problem's content and returns it with its new version
+func (s *Store) Update(problem model.Problem) (*model.Problem, error) {
+	return s.edit(problem.ID, func(p *model.Problem) error {
+		*p = problem
+		return nil
+	})
+}
+
+// SetArchived archives a problem, which takes it out of listings and closes it to
+// submissions, or restores it
+func (s *Store) SetArchived(id string, archived bool) (*model.Problem, error) {
+	return s.edit(id, func(p *model.Problem) error {
+		p.Archived = archived
+		return nil
+	})
+}
+
+// SetDraft withdraws a problem to a draft or publishes it. Callers check its test
+// inputs before publishing.
+func (s *Store) SetDraft(id string, draft bool) (*model.Problem, error) {
+	return s.edit(id, func(p *model.Problem) error {
+		p.Draft = draft
+		p.PendingValidation = false
+		return nil
+	})
+}
+
+// PutTest replaces the 1-based test n of a problem, or appends it when n is one past the last
+func (s *Store) PutTest(id string, n int, tc model.TestCase) (*model.Problem, error) {
+	return s.edit(id, func(p *model.Problem) error {
+		switch {
+		case n >= 1 && n <= len(p.TestCases):
+			p.TestCases[n-1] = tc
+		case n == len(p.TestCases)+1:
+			p.TestCases = append(p.TestCases, tc)
+		default:
+			return fmt.Errorf("%w: %d", ErrTestNotFound, n)
+		}
+		return nil
+	})
+}
+
+// DeleteTest removes the 1-based test n of a problem. Later tests move up by one,
+// so subtasks that refer to them must be updated first.
+func (s *Store) DeleteTest(id string, n int) (*model.Problem, error) {
+	return s.edit(id, func(p *model.Problem) error {
+		if n < 1 || n > len(p.TestCases) {
+			return fmt.Errorf("%w: %d", ErrTestNotFound, n)
+		}
+		p.TestCases = append(p.TestCases[:n-1], p.TestCases[n:]...)
+		return nil
+	})
+}
+
+// Delete removes a problem's folder. Its recorded versions are kept.
+func (s *Store) Delete(id string) error {
+	s.writes.Lock()
+	defer s.writes.Unlock()
+	if s.dir == "" {
+		return ErrReadOnly
+	}
+	folder, ok := s.folderOf(id)
+	if !ok {
+		return ErrProblemNotFound
+	}
+	if err := os.RemoveAll(filepath.Join(s.dir, folder)); err != nil {
+		return fmt.Errorf("failed to delete problem: %v", err)
+	}
+	return s.Reload()
+}
+
+// edit applies a change to a copy of a problem and saves the result
+func (s *Store) edit(id string, change func(*model.Problem) error) (*model.Problem, error) {
+	s.writes.Lock()
+	defer s.writes.Unlock()
+	if s.dir == "" {
+		return nil, ErrReadOnly
+	}
+	current, ok := s.Get(id)
+	if !ok {
+		return nil, ErrProblemNotFound
+	}
+	folder, _ := s.folderOf(id)
+
+	updated := *current
+	updated.TestCases = append([]model.TestCase(nil), current.TestCases...)
+	if err := change(&updated); err != nil {
+		return nil, err
+	}
+	if updated.ID != id {
+		return nil, fmt.Errorf("problem id cannot change from %s to %s", id, updated.ID)
+	}
+	return s.save(updated, folder)
+}
+
+// save writes a problem to its folder, reloads, and returns it as stored. A problem
+// loaded from a folder not named after its ID moves to one that is.
+func (s *Store) save(problem model.Problem, folder string) (*model.Problem, error) {
+	problem.Version = 0
+	if err := WriteFolder(s.dir, &problem, true); err != nil {
+		return nil, err
+	}
+	if folder != "" && folder != problem.ID {
+		if err := os.RemoveAll(filepath.Join(s.dir, folder)); err != nil {
+			return nil, fmt.Errorf("failed to remove old problem folder: %v", err)
+		}
+	}
+	if err := s.Reload(); err != nil {
+		return nil, err
+	}
+	saved, ok := s.Get(problem.ID)
+	if !ok {
+		return nil, fmt.Errorf("problem %s did not load after saving", problem.ID)
+	}
+	return saved, nil
+}
+
+// folderOf finds the folder a problem was loaded from
+func (s *Store) folderOf(id string) (string, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	for folder, p := range s.folders {
+		if p.ID == id {
+			return folder, true
+		}
+	}
+	return "", false
+}
@@ -0,0 +1,83 @@
+package problems
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+
+	"xcodeengine/model"
+)
+
+// Caches inside the problems directory: generated test inputs by hash, and the
+// validator runs that accepted a problem's inputs
+const (
+	inputCacheDir     = ".cache/inputs"
+	validatedCacheDir = ".cache/validated"
+)
+
+// validHash matches the hex digests that name cached inputs
+var validHash = regexp.MustCompile(`^[0-9a-f]{64}$`)
+
+// CachedInput returns the generated input stored under a hash
+func (s *Store) CachedInput(hash string) (string, bool) {
+	if s.dir == "" || !validHash.MatchString(hash) {
+		return "", false
+	}
+	data, err := os.ReadFile(filepath.Join(s.dir, inputCacheDir, hash))
+	if err != nil {
+		return "", false
+	}
+	return string(data), true
+}
+
+// CacheInput stores a generated input under its hash. The file appears whole or not
+// at all, so concurrent generations never read half an input.
+func (s *Store) CacheInput(hash, input string) error {
+	if s.dir == "" {
+		return ErrReadOnly
+	}
+	if !validHash.MatchString(hash) {
+		return os.ErrInvalid
+	}
+	dir := filepath.Join(s.dir, inputCacheDir)
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(dir, ".tmp-")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.WriteString(input); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), filepath.Join(dir, hash))
+}
+
+// inputsDigest identifies a problem's validator together with its test inputs, which
+// is all a validation run depends on
+func inputsDigest(p *model.Problem) string {
+	h := sha256.New()
+	fmt.Fprintf(h, "%s\x00%d\x00%s\x00", p.Validator.Language, len(p.Validator.Source), p.Validator.Source)
+	for _, tc := range p.TestCases {
+		fmt.Fprintf(h, "%d\x00%s\x00", len(tc.Input), tc.Input)
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// inputsValidated reports whether the validator has accepted a problem's inputs as
+// they are. Problems without a validator, and built-in ones, need no run.
+func (s *Store) inputsValidated(p *model.Problem) bool {
+	if s.dir == "" || p.Validator == nil {
+		return true
+	}
+	_, err := os.Stat(filepath.Join(s.dir, validatedCacheDir, inputsDigest(p)))
+	return err == nil
+}
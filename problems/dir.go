@@ -0,0 +1,378 @@
+package problems
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+
+	"xcodeengine/model"
+)
+
+// Files that make up a problem folder
+const (
+	manifestFile  = "problem.yaml"
+	statementFile = "statement.md"
+	testsDir      = "tests"
+	generatorsDir = "generators"
+	inputExt      = ".in"
+	outputExt     = ".out"
+)
+
+// manifest is the problem.yaml of a problem folder. Checker, interactor, validator,
+// solution and statement name files relative to the folder.
+type manifest struct {
+	ID               string                           `yaml:"id,omitempty"` // defaults to the folder name
+	Title            string                           `yaml:"title,omitempty"`
+	Statement        string                           `yaml:"statement,omitempty"` // defaults to statement.md
+	InputFormat      string                           `yaml:"input_format,omitempty"`
+	Constraints      string                           `yaml:"constraints,omitempty"`
+	TimeLimit        int                              `yaml:"time_limit_ms,omitempty"`
+	MemoryLimit      int                              `yaml:"memory_limit_mb,omitempty"`
+	LimitMultipliers map[string]model.LimitMultiplier `yaml:"limit_multipliers,omitempty"`
+	DefaultFlags     map[string][]string              `yaml:"default_flags,omitempty"`
+	Comparator       comparatorFile                   `yaml:"comparator,omitempty"`
+	Function         *functionFile                    `yaml:"function,omitempty"`
+	Checker          *programFile                     `yaml:"checker,omitempty"`
+	Interactor       *programFile                     `yaml:"interactor,omitempty"`
+	Validator        *programFile                     `yaml:"validator,omitempty"`
+	Solution         *programFile                     `yaml:"solution,omitempty"`
+	Generators       []generatorFile                  `yaml:"generators,omitempty"`
+	TestScript       []scriptLine                     `yaml:"test_script,omitempty"`
+	// Samples names the tests shown to participants; when unset, tests whose names
+	// start with "sample" are samples. Every other test is hidden.
+	Samples []string `yaml:"samples,omitempty"`
+	// TestNames gives tests display names, keyed by file name; tests default to it
+	TestNames map[string]string `yaml:"test_names,omitempty"`
+	// Generated names the test files materialised from the test script
+	Generated []string      `yaml:"generated,omitempty"`
+	Subtasks  []subtaskFile `yaml:"subtasks,omitempty"`
+	JudgeMode string        `yaml:"judge_mode,omitempty"`
+	Archived  bool          `yaml:"archived,omitempty"` // unlisted and closed to submissions
+	Draft     bool          `yaml:"draft,omitempty"`    // not yet published
+}
+
+type comparatorFile struct {
+	Mode              string  `yaml:"mode,omitempty"`
+	Epsilon           float64 `yaml:"epsilon,omitempty"`
+	PresentationError bool    `yaml:"presentation_error,omitempty"`
+}
+
+// functionFile declares the function of a function-signature problem
+type functionFile struct {
+	Name    string          `yaml:"name"`
+	Params  []parameterFile `yaml:"params"`
+	Returns string          `yaml:"returns"`
+}
+
+type parameterFile struct {
+	Name string `yaml:"name"`
+	Type string `yaml:"type"`
+}
+
+// programFile names a judge program or solution source in a problem folder
+type programFile struct {
+	Language string `yaml:"language"`
+	Source   string `yaml:"source"`
+}
+
+// generatorFile names a generator source in a problem folder
+type generatorFile struct {
+	Name     string `yaml:"name"`
+	Language string `yaml:"language"`
+	Source   string `yaml:"source"`
+}
+
+// scriptLine is one generator run of a test script
+type scriptLine struct {
+	Generator string   `yaml:"generator"`
+	Args      []string `yaml:"args,omitempty,flow"`
+	Seed      int64    `yaml:"seed"`
+	Name      string   `yaml:"name,omitempty"`
+	Sample    bool     `yaml:"sample,omitempty"`
+}
+
+type subtaskFile struct {
+	Name      string   `yaml:"name"`
+	Score     float64  `yaml:"score"`
+	Scoring   string   `yaml:"scoring,omitempty"`
+	Tests     []int    `yaml:"tests"`
+	DependsOn []string `yaml:"depends_on,omitempty"`
+}
+
+// loadDir reads every problem folder under dir, keyed by folder name. Folders without
+// a problem.yaml are ignored; a folder that fails to load is reported in errs.
+func loadDir(dir string) (map[string]model.Problem, map[string]error, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to read problems directory: %v", err)
+	}
+
+	loaded := make(map[string]model.Problem)
+	errs := make(map[string]error)
+	for _, entry := range entries {
+		if !entry.IsDir() || strings.HasPrefix(entry.Name(), ".") {
+			continue
+		}
+		folder := filepath.Join(dir, entry.Name())
+		if _, err := os.Stat(filepath.Join(folder, manifestFile)); errors.Is(err, fs.ErrNotExist) {
+			continue
+		}
+		problem, err := loadProblem(folder)
+		if err != nil {
+			errs[entry.Name()] = err
+			continue
+		}
+		loaded[entry.Name()] = *problem
+	}
+	return loaded, errs, nil
+}
+
+// loadProblem reads and validates one problem folder
+func loadProblem(folder string) (*model.Problem, error) {
+	data, err := os.ReadFile(filepath.Join(folder, manifestFile))
+	if err != nil {
+		return nil, err
+	}
+	var m manifest
+	decoder := yaml.NewDecoder(bytes.NewReader(data))
+	decoder.KnownFields(true)
+	if err := decoder.Decode(&m); err != nil {
+		return nil, fmt.Errorf("%s: %v", manifestFile, err)
+	}
+
+	problem := &model.Problem{
+		ID:               m.ID,
+		Title:            m.Title,
+		InputFormat:      m.InputFormat,
+		Constraints:      m.Constraints,
+		TimeLimit:        m.TimeLimit,
+		MemoryLimit:      m.MemoryLimit,
+		LimitMultipliers: m.LimitMultipliers,
+		DefaultFlags:     m.DefaultFlags,
+		Comparator: model.Comparator{
+			Mode:              m.Comparator.Mode,
+			Epsilon:           m.Comparator.Epsilon,
+			PresentationError: m.Comparator.PresentationError,
+		},
+		JudgeMode: m.JudgeMode,
+		Archived:  m.Archived,
+		Draft:     m.Draft,
+	}
+	if problem.ID == "" {
+		problem.ID = filepath.Base(folder)
+	}
+	if m.Function != nil {
+		problem.Function = &model.Function{Name: m.Function.Name, Returns: m.Function.Returns}
+		for _, param := range m.Function.Params {
+			problem.Function.Params = append(problem.Function.Params, model.Parameter{Name: param.Name, Type: param.Type})
+		}
+	}
+
+	statement := m.Statement
+	if statement == "" {
+		statement = statementFile
+	}
+	description, err := readFolderFile(folder, statement)
+	if err != nil && (m.Statement != "" || !errors.Is(err, fs.ErrNotExist)) {
+		return nil, err
+	}
+	problem.Description = description
+
+	if m.Checker != nil {
+		source, err := readFolderFile(folder, m.Checker.Source)
+		if err != nil {
+			return nil, fmt.Errorf("checker: %v", err)
+		}
+		problem.Checker = &model.Checker{Language: m.Checker.Language, Source: source}
+	}
+	if m.Interactor != nil {
+		source, err := readFolderFile(folder, m.Interactor.Source)
+		if err != nil {
+			return nil, fmt.Errorf("interactor: %v", err)
+		}
+		problem.Interactor = &model.Interactor{Language: m.Interactor.Language, Source: source}
+	}
+	if m.Validator != nil {
+		source, err := readFolderFile(folder, m.Validator.Source)
+		if err != nil {
+			return nil, fmt.Errorf("validator: %v", err)
+		}
+		problem.Validator = &model.Validator{Language: m.Validator.Language, Source: source}
+	}
+	for _, g := range m.Generators {
+		source, err := readFolderFile(folder, g.Source)
+		if err != nil {
+			return nil, fmt.Errorf("generator %s: %v", g.Name, err)
+		}
+		problem.Generators = append(problem.Generators, model.Generator{Name: g.Name, Language: g.Language, Source: source})
+	}
+	for _, line := range m.TestScript {
+		problem.TestScript = append(problem.TestScript, model.GeneratedTest{
+			Generator: line.Generator,
+			Args:      line.Args,
+			Seed:      line.Seed,
+			Name:      line.Name,
+			Sample:    line.Sample,
+		})
+	}
+	if m.Solution != nil {
+		source, err := readFolderFile(folder, m.Solution.Source)
+		if err != nil {
+			return nil, fmt.Errorf("solution: %v", err)
+		}
+		problem.Solution = &model.Solution{Language: m.Solution.Language, Source: source}
+	}
+
+	problem.TestCases, err = loadTests(filepath.Join(folder, testsDir), m.Samples, m.TestNames, m.Generated, problem.Interactor != nil)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, s := range m.Subtasks {
+		problem.Subtasks = append(problem.Subtasks, model.Subtask{
+			Name:      s.Name,
+			Score:     s.Score,
+			Scoring:   s.Scoring,
+			Tests:     s.Tests,
+			DependsOn: s.DependsOn,
+		})
+	}
+
+	if err := Validate(problem); err != nil {
+		return nil, err
+	}
+	return problem, nil
+}
+
+// loadTests pairs every NAME.in in the tests folder with NAME.out, in natural name
+// order. Interactive problems need no answer files.
+func loadTests(dir string, samples []string, displayNames map[string]string, generated []string, interactive bool) ([]model.TestCase, error) {
+	inputs, err := filepath.Glob(filepath.Join(dir, "*"+inputExt))
+	if err != nil {
+		return nil, err
+	}
+	names := make([]string, 0, len(inputs))
+	for _, input := range inputs {
+		names = append(names, strings.TrimSuffix(filepath.Base(input), inputExt))
+	}
+	sort.Slice(names, func(i, j int) bool { return naturalLess(names[i], names[j]) })
+
+	isSample := func(name string) bool {
+		if samples == nil {
+			return strings.HasPrefix(strings.ToLower(name), "sample")
+		}
+		for _, sample := range samples {
+			if sample == name {
+				return true
+			}
+		}
+		return false
+	}
+
+	tests := make([]model.TestCase, 0, len(names))
+	for _, name := range names {
+		input, err := os.ReadFile(filepath.Join(dir, name+inputExt))
+		if err != nil {
+			return nil, err
+		}
+		expected, err := os.ReadFile(filepath.Join(dir, name+outputExt))
+		if err != nil && (!interactive || !errors.Is(err, fs.ErrNotExist)) {
+			return nil, fmt.Errorf("test %s: %v", name, err)
+		}
+		display := name
+		if displayNames[name] != "" {
+			display = displayNames[name]
+		}
+		tests = append(tests, model.TestCase{
+			Name:           display,
+			Input:          string(input),
+			ExpectedOutput: string(expected),
+			Hidden:         !isSample(name),
+			Generated:      contains(generated, name),
+		})
+	}
+	return tests, nil
+}
+
+// readFolderFile reads a file named by problem.yaml, which must stay inside the folder
+func readFolderFile(folder, name string) (string, error) {
+	if name == "" {
+		return "", fmt.Errorf("no file named")
+	}
+	if !filepath.IsLocal(name) {
+		return "", fmt.Errorf("file %s is outside the problem folder", name)
+	}
+	data, err := os.ReadFile(filepath.Join(folder, name))
+	if err != nil {
+		return "", err
+	}
+	return string(data), nil
+}
+
+// naturalLess orders test names so that "2" comes before "10" and "test2" before
+// "test10": runs of digits compare by value, everything else byte by byte
+func naturalLess(a, b string) bool {
+	for a != "" && b != "" {
+		x, restA := leadingRun(a)
+		y, restB := leadingRun(b)
+		if x != y {
+			if isDigit(x[0]) && isDigit(y[0]) {
+				// Equal values with different zero padding fall back to the shorter run first
+				if nx, ny := strings.TrimLeft(x, "0"), strings.TrimLeft(y, "0"); nx != ny {
+					if len(nx) != len(ny) {
+						return len(nx) < len(ny)
+					}
+					return nx < ny
+				}
+				return len(x) < len(y)
+			}
+			return x < y
+		}
+		a, b = restA, restB
+	}
+	return len(a) < len(b)
+}
+
+// leadingRun splits off the leading run of digits or of non-digits of a non-empty name
+func leadingRun(s string) (string, string) {
+	digits := isDigit(s[0])
+	i := 1
+	for i < len(s) && isDigit(s[i]) == digits {
+		i++
+	}
+	return s[:i], s[i:]
+}
+
+func isDigit(c byte) bool {
+	return '0' <= c && c <= '9'
+}
+
+// fingerprint summarises the names, sizes and modification times of every file
+// under dir, so that a change anywhere shows up as a different value
+func fingerprint(dir string) (uint64, error) {
+	h := fnv.New64a()
+	err := filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		// Version history and folders being written are not part of the problem set
+		if d.IsDir() && path != dir && strings.HasPrefix(d.Name(), ".") {
+			return fs.SkipDir
+		}
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(h, "%s\x00%d\x00%d\x00", path, info.Size(), info.ModTime().UnixNano())
+		return nil
+	})
+	return h.Sum64(), err
+}
@@ -0,0 +1,149 @@
+package problems
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"math"
+	"regexp"
+	"strings"
+
+	"xcodeengine/model"
+)
+
+// validIdentifier matches function and parameter names that every harness language accepts
+var validIdentifier = regexp.MustCompile(`^[A-Za-z][A-Za-z0-9_]*$`)
+
+// ParseType splits a function-signature type such as "int[][]" into its base type
+// and how many list levels wrap it
+func ParseType(name string) (string, int, error) {
+	base, depth := name, 0
+	for strings.HasSuffix(base, "[]") {
+		base = strings.TrimSuffix(base, "[]")
+		depth++
+	}
+	switch base {
+	case model.TypeInt, model.TypeLong, model.TypeDouble, model.TypeBool, model.TypeString:
+		return base, depth, nil
+	}
+	return "", 0, fmt.Errorf("unknown type: %q", name)
+}
+
+// validateFunction checks a function-signature problem: its signature, that it
+// compares values, and that every test holds arguments and a return value of the
+// declared types. Generated tests may lack an output until the reference runs.
+func validateFunction(problem *model.Problem) error {
+	fn := problem.Function
+	if !validIdentifier.MatchString(fn.Name) {
+		return fmt.Errorf("invalid function name: %q", fn.Name)
+	}
+	if problem.Interactor != nil {
+		return fmt.Errorf("a function problem cannot have an interactor")
+	}
+	switch problem.Comparator.Mode {
+	case "", model.CompareValues:
+	default:
+		return fmt.Errorf("function problems compare values, not %s", problem.Comparator.Mode)
+	}
+	seen := make(map[string]bool, len(fn.Params))
+	for _, param := range fn.Params {
+		if !validIdentifier.MatchString(param.Name) || param.Name == fn.Name {
+			return fmt.Errorf("invalid parameter name: %q", param.Name)
+		}
+		if seen[param.Name] {
+			return fmt.Errorf("duplicate parameter: %s", param.Name)
+		}
+		seen[param.Name] = true
+		if _, _, err := ParseType(param.Type); err != nil {
+			return fmt.Errorf("parameter %s: %w", param.Name, err)
+		}
+	}
+	if _, _, err := ParseType(fn.Returns); err != nil {
+		return fmt.Errorf("return type: %w", err)
+	}
+
+	for i, tc := range problem.TestCases {
+		if err := checkArguments(fn, tc.Input); err != nil {
+			return fmt.Errorf("test %d input: %w", i+1, err)
+		}
+		if tc.Generated && strings.TrimSpace(tc.ExpectedOutput) == "" {
+			continue
+		}
+		if err := checkValue(fn.Returns, tc.ExpectedOutput); err != nil {
+			return fmt.Errorf("test %d output: %w", i+1, err)
+		}
+	}
+	return nil
+}
+
+// checkArguments checks that an input is a JSON array of the function's arguments
+func checkArguments(fn *model.Function, input string) error {
+	var args []json.RawMessage
+	if err := json.Unmarshal([]byte(input), &args); err != nil {
+		return fmt.Errorf("want a JSON array of arguments: %v", err)
+	}
+	if len(args) != len(fn.Params) {
+		return fmt.Errorf("want %d arguments, got %d", len(fn.Params), len(args))
+	}
+	for i, param := range fn.Params {
+		if err := checkValue(param.Type, string(args[i])); err != nil {
+			return fmt.Errorf("%s: %w", param.Name, err)
+		}
+	}
+	return nil
+}
+
+// checkValue checks that a JSON text holds a value of a function-signature type
+func checkValue(typ, text string) error {
+	base, depth, err := ParseType(typ)
+	if err != nil {
+		return err
+	}
+	decoder := json.NewDecoder(bytes.NewReader([]byte(text)))
+	decoder.UseNumber()
+	var value any
+	if err := decoder.Decode(&value); err != nil {
+		return fmt.Errorf("invalid JSON: %v", err)
+	}
+	if decoder.More() {
+		return fmt.Errorf("want one JSON value")
+	}
+	return checkTyped(base, depth, value)
+}
+
+// checkTyped checks a decoded JSON value against a base type nested in depth lists
+func checkTyped(base string, depth int, value any) error {
+	if depth > 0 {
+		list, ok := value.([]any)
+		if !ok {
+			return fmt.Errorf("want a list, got %v", value)
+		}
+		for _, element := range list {
+			if err := checkTyped(base, depth-1, element); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	ok := false
+	switch base {
+	case model.TypeInt:
+		n, isNumber := value.(json.Number)
+		i, err := n.Int64()
+		ok = isNumber && err == nil && i >= math.MinInt32 && i <= math.MaxInt32
+	case model.TypeLong:
+		n, isNumber := value.(json.Number)
+		_, err := n.Int64()
+		ok = isNumber && err == nil
+	case model.TypeDouble:
+		_, ok = value.(json.Number)
+	case model.TypeBool:
+		_, ok = value.(bool)
+	case model.TypeString:
+		_, ok = value.(string)
+	}
+	if !ok {
+		return fmt.Errorf("want %s, got %v", base, value)
+	}
+	return nil
+}
@@ -0,0 +1,161 @@
+package problems
+
+import (
+	"archive/zip"
+	"bytes"
+	"errors"
+	"fmt"
+	"io/fs"
+	"path"
+	"regexp"
+	"strings"
+
+	"xcodeengine/model"
+)
+
+// Problem package formats the importer reads
+const (
+	FormatPolygon = "polygon" // Codeforces Polygon packages, described by problem.xml
+	FormatKattis  = "kattis"  // Kattis problem packages, described by problem.yaml
+)
+
+// maxPackageSize caps the unpacked size of an imported package
+const maxPackageSize = 256 << 20
+
+// sourceLanguages maps source file extensions to engine languages
+var sourceLanguages = map[string]string{
+	".cpp":  "cpp",
+	".cc":   "cpp",
+	".cxx":  "cpp",
+	".c":    "c",
+	".py":   "python",
+	".java": "java",
+	".go":   "go",
+	".js":   "js",
+}
+
+// invalidIDChars matches what a package name needs replaced to become a problem ID
+var invalidIDChars = regexp.MustCompile(`[^a-z0-9]+`)
+
+// OpenPackage opens a zipped problem package. Archives that wrap everything in a
+// single top-level folder are opened at that folder, whose name is returned as well.
+func OpenPackage(data []byte) (fs.FS, string, error) {
+	archive, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		return nil, "", fmt.Errorf("invalid package archive: %v", err)
+	}
+	var size uint64
+	for _, file := range archive.File {
+		size += file.UncompressedSize64
+	}
+	if size > maxPackageSize {
+		return nil, "", fmt.Errorf("package unpacks to more than %d MB", maxPackageSize>>20)
+	}
+
+	entries, err := fs.ReadDir(archive, ".")
+	if err != nil {
+		return nil, "", fmt.Errorf("invalid package archive: %v", err)
+	}
+	if len(entries) == 1 && entries[0].IsDir() {
+		root, err := fs.Sub(archive, entries[0].Name())
+		return root, entries[0].Name(), err
+	}
+	return archive, "", nil
+}
+
+// DetectFormat tells a Polygon package from a Kattis one by its descriptor
+func DetectFormat(pkg fs.FS) (string, error) {
+	if _, err := fs.Stat(pkg, "problem.xml"); err == nil {
+		return FormatPolygon, nil
+	}
+	if _, err := fs.Stat(pkg, "problem.yaml"); err == nil {
+		return FormatKattis, nil
+	}
+	return "", fmt.Errorf("unrecognised package: no problem.xml or problem.yaml")
+}
+
+// Import converts a problem package into a problem. An empty format is detected from
+// the package. An empty id falls back to the Polygon short name, or for Kattis to the
+// package name: its folder or archive name. Warnings name the parts of the package
+// that were approximated or left out.
+func Import(pkg fs.FS, name, format, id string) (*model.Problem, []string, error) {
+	if format == "" {
+		detected, err := DetectFormat(pkg)
+		if err != nil {
+			return nil, nil, err
+		}
+		format = detected
+	}
+
+	var (
+		problem  *model.Problem
+		warnings []string
+		err      error
+	)
+	switch format {
+	case FormatPolygon:
+		problem, warnings, err = importPolygon(pkg)
+	case FormatKattis:
+		problem, warnings, err = importKattis(pkg, name)
+	default:
+		return nil, nil, fmt.Errorf("unknown package format: %s", format)
+	}
+	if err != nil {
+		return nil, nil, fmt.Errorf("%s package: %w", format, err)
+	}
+
+	if id != "" {
+		problem.ID = id
+	}
+	problem.ID = problemID(problem.ID)
+	if err := Validate(problem); err != nil {
+		return nil, nil, err
+	}
+	return problem, warnings, nil
+}
+
+// problemID turns a package name into a problem ID
+func problemID(name string) string {
+	return strings.Trim(invalidIDChars.ReplaceAllString(strings.ToLower(name), "-"), "-")
+}
+
+// readPackageFile reads a file from a package as text
+func readPackageFile(pkg fs.FS, name string) (string, error) {
+	data, err := fs.ReadFile(pkg, name)
+	if err != nil {
+		return "", err
+	}
+	return string(data), nil
+}
+
+// readOptional reads a file that a package may leave out, returning "" when it is missing
+func readOptional(pkg fs.FS, name string) (string, error) {
+	content, err := readPackageFile(pkg, name)
+	if errors.Is(err, fs.ErrNotExist) {
+		return "", nil
+	}
+	return content, err
+}
+
+// SourceLanguage picks the engine language of a source file from its name
+func SourceLanguage(name string) (string, error) {
+	language, ok := sourceLanguages[strings.ToLower(path.Ext(name))]
+	if !ok {
+		return "", fmt.Errorf("no supported language for %s", name)
+	}
+	return language, nil
+}
+
+// usesTestlib reports whether a judge program is written against testlib
+func usesTestlib(source string) bool {
+	return strings.Contains(source, "testlib.h")
+}
+
+// section formats one part of a statement as a markdown section, or nothing when empty
+func section(title, body string) string {
+	body = strings.TrimSpace(body)
+	if body == "" {
+		return ""
+	}
+	return fmt.Sprintf("### %s\n%s\n\n", title, body)
+}
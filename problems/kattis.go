@@ -0,0 +1,349 @@
+package problems
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"path"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+
+	"xcodeengine/model"
+)
+
+// kattisProblem is the part of a Kattis problem.yaml the importer reads; it accepts
+// both the legacy and the 2023 layout
+type kattisProblem struct {
+	Name           yaml.Node `yaml:"name"` // a string, or a map of language to string
+	Type           string    `yaml:"type"`
+	Validation     string    `yaml:"validation"`
+	ValidatorFlags string    `yaml:"validator_flags"`
+	Limits         struct {
+		TimeLimit float64 `yaml:"time_limit"` // seconds
+		Memory    int     `yaml:"memory"`     // MB
+	} `yaml:"limits"`
+}
+
+// kattisGroup is the testdata.yaml or test_group.yaml of a test group
+type kattisGroup struct {
+	Range    string  `yaml:"range"`     // legacy "min max" score range
+	MaxScore float64 `yaml:"max_score"` // 2023 layout
+}
+
+// Places a Kattis package may keep its statement and judge programs, in order of preference
+var (
+	kattisStatements = []string{
+		"problem_statement/problem.en.md", "problem_statement/problem.md", "statement/problem.en.md",
+		"problem_statement/problem.en.tex", "problem_statement/problem.tex", "statement/problem.en.tex",
+	}
+	kattisOutputValidators = []string{"output_validators", "output_validator"}
+	kattisInputValidators  = []string{"input_validators", "input_validator"}
+)
+
+// problemName matches the title in a LaTeX statement
+var problemName = regexp.MustCompile(`\\problemname\{([^}]*)\}`)
+
+// importKattis reads a Kattis problem package. Its default output validator maps to a
+// comparator; custom validators and interactors must be testlib programs, since the
+// engine does not speak the Kattis validator protocol.
+func importKattis(pkg fs.FS, name string) (*model.Problem, []string, error) {
+	data, err := fs.ReadFile(pkg, "problem.yaml")
+	if err != nil {
+		return nil, nil, err
+	}
+	var desc kattisProblem
+	if err := yaml.Unmarshal(data, &desc); err != nil {
+		return nil, nil, fmt.Errorf("problem.yaml: %v", err)
+	}
+
+	var warnings []string
+	problem := &model.Problem{
+		ID:          name,
+		Title:       kattisName(desc.Name),
+		MemoryLimit: desc.Limits.Memory,
+	}
+	for _, name := range kattisStatements {
+		statement, err := readOptional(pkg, name)
+		if err != nil {
+			return nil, nil, err
+		}
+		if statement == "" {
+			continue
+		}
+		if m := problemName.FindStringSubmatch(statement); m != nil && problem.Title == "" {
+			problem.Title = m[1]
+		}
+		problem.Description = statement
+		break
+	}
+	if problem.ID == "" {
+		problem.ID = problem.Title
+	}
+
+	timeLimit := desc.Limits.TimeLimit
+	if timeLimit == 0 {
+		if content, err := readOptional(pkg, ".timelimit"); err == nil && content != "" {
+			timeLimit, _ = strconv.ParseFloat(strings.TrimSpace(content), 64)
+		}
+	}
+	if timeLimit > 0 {
+		problem.TimeLimit = int(timeLimit * 1000)
+	} else {
+		warnings = append(warnings, "the package sets no time limit; the language timeout applies")
+	}
+
+	validation := strings.Fields(desc.Validation)
+	interactive := desc.Type == "interactive" || contains(validation, "interactive")
+	custom := interactive || contains(validation, "custom")
+	if custom {
+		lang, source, err := kattisProgram(pkg, kattisOutputValidators)
+		if err != nil {
+			return nil, nil, fmt.Errorf("output validator: %v", err)
+		}
+		if !usesTestlib(source) {
+			return nil, nil, fmt.Errorf("the output validator uses the Kattis validator protocol; port it to testlib to import it")
+		}
+		if interactive {
+			problem.Interactor = &model.Interactor{Language: lang, Source: source}
+		} else {
+			problem.Checker = &model.Checker{Language: lang, Source: source}
+		}
+	} else {
+		problem.Comparator = kattisComparator(desc.ValidatorFlags)
+	}
+
+	if lang, source, err := kattisProgram(pkg, kattisInputValidators); err == nil {
+		if usesTestlib(source) {
+			problem.Validator = &model.Validator{Language: lang, Source: source}
+		} else {
+			warnings = append(warnings, "the input validator is not a testlib program and was left out")
+		}
+	} else if !errors.Is(err, fs.ErrNotExist) {
+		warnings = append(warnings, fmt.Sprintf("input validator left out: %v", err))
+	}
+
+	if lang, source, err := kattisSolution(pkg); err == nil {
+		problem.Solution = &model.Solution{Language: lang, Source: source}
+	}
+
+	samples, err := kattisTests(pkg, "data/sample", interactive)
+	if err != nil {
+		return nil, nil, err
+	}
+	for i := range samples {
+		samples[i].test.Name = fmt.Sprintf("Sample #%d", i+1)
+		samples[i].test.Hidden = false
+		problem.TestCases = append(problem.TestCases, samples[i].test)
+	}
+	secret, err := kattisTests(pkg, "data/secret", interactive)
+	if err != nil {
+		return nil, nil, err
+	}
+	groups := make(map[string][]int)
+	var order []string
+	for _, t := range secret {
+		problem.TestCases = append(problem.TestCases, t.test)
+		if t.group == "" {
+			continue
+		}
+		if _, ok := groups[t.group]; !ok {
+			order = append(order, t.group)
+		}
+		groups[t.group] = append(groups[t.group], len(problem.TestCases))
+	}
+
+	if desc.Type == "scoring" || contains(validation, "score") {
+		for _, group := range order {
+			subtask := model.Subtask{Name: group, Scoring: model.ScoringAll, Tests: groups[group]}
+			subtask.Score, err = kattisGroupScore(pkg, path.Join("data/secret", group))
+			if err != nil {
+				warnings = append(warnings, fmt.Sprintf("group %s: %v", group, err))
+			}
+			problem.Subtasks = append(problem.Subtasks, subtask)
+		}
+	} else if len(order) > 0 {
+		warnings = append(warnings, "test groups of a pass-fail problem were flattened")
+	}
+
+	return problem, warnings, nil
+}
+
+// kattisTest is one test of a Kattis package with the group folder it sits in
+type kattisTest struct {
+	test  model.TestCase
+	group string
+}
+
+// kattisTests reads every NAME.in under dir with its NAME.ans, in path order; tests
+// in a subfolder belong to the group named after its top-level folder
+func kattisTests(pkg fs.FS, dir string, interactive bool) ([]kattisTest, error) {
+	var inputs []string
+	err := fs.WalkDir(pkg, dir, func(name string, d fs.DirEntry, err error) error {
+		if err != nil {
+			if errors.Is(err, fs.ErrNotExist) && name == dir {
+				return fs.SkipDir
+			}
+			return err
+		}
+		if !d.IsDir() && strings.HasSuffix(name, inputExt) {
+			inputs = append(inputs, name)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	sort.Strings(inputs)
+
+	tests := make([]kattisTest, 0, len(inputs))
+	for _, input := range inputs {
+		base := strings.TrimSuffix(input, inputExt)
+		in, err := readPackageFile(pkg, input)
+		if err != nil {
+			return nil, err
+		}
+		answer, err := readPackageFile(pkg, base+".ans")
+		if err != nil && (!interactive || !errors.Is(err, fs.ErrNotExist)) {
+			return nil, fmt.Errorf("test %s: %v", base, err)
+		}
+		rel := strings.TrimPrefix(base, dir+"/")
+		group, _, nested := strings.Cut(rel, "/")
+		if !nested {
+			group = ""
+		}
+		tests = append(tests, kattisTest{
+			test:  model.TestCase{Name: rel, Input: in, ExpectedOutput: answer, Hidden: true},
+			group: group,
+		})
+	}
+	return tests, nil
+}
+
+// kattisGroupScore reads the maximum score of a test group
+func kattisGroupScore(pkg fs.FS, dir string) (float64, error) {
+	for _, file := range []string{"test_group.yaml", "testdata.yaml"} {
+		content, err := readOptional(pkg, path.Join(dir, file))
+		if err != nil || content == "" {
+			continue
+		}
+		var group kattisGroup
+		if err := yaml.Unmarshal([]byte(content), &group); err != nil {
+			return 0, fmt.Errorf("%s: %v", file, err)
+		}
+		if group.MaxScore > 0 {
+			return group.MaxScore, nil
+		}
+		if bounds := strings.Fields(group.Range); len(bounds) == 2 {
+			return strconv.ParseFloat(bounds[1], 64)
+		}
+	}
+	return 0, fmt.Errorf("no score found; it scores 0")
+}
+
+// kattisComparator maps the flags of the default output validator to a comparator.
+// The default validator compares tokens, ignoring case unless told otherwise.
+func kattisComparator(flags string) model.Comparator {
+	fields := strings.Fields(flags)
+	cmp := model.Comparator{Mode: model.CompareCaseInsensitive}
+	if contains(fields, "case_sensitive") {
+		cmp.Mode = model.CompareTokens
+	}
+	if contains(fields, "space_change_sensitive") {
+		cmp.Mode = model.CompareExact
+	}
+	for i, flag := range fields {
+		switch flag {
+		case "float_tolerance", "float_absolute_tolerance", "float_relative_tolerance":
+			if i+1 < len(fields) {
+				if epsilon, err := strconv.ParseFloat(fields[i+1], 64); err == nil {
+					cmp = model.Comparator{Mode: model.CompareFloat, Epsilon: epsilon}
+				}
+			}
+		}
+	}
+	return cmp
+}
+
+// kattisProgram finds the single judge program source under the first of dirs that exists
+func kattisProgram(pkg fs.FS, dirs []string) (string, string, error) {
+	for _, dir := range dirs {
+		if _, err := fs.Stat(pkg, dir); err != nil {
+			continue
+		}
+		var sources []string
+		err := fs.WalkDir(pkg, dir, func(name string, d fs.DirEntry, err error) error {
+			if err != nil {
+				return err
+			}
+			if _, ok := sourceLanguages[strings.ToLower(path.Ext(name))]; ok && !d.IsDir() {
+				sources = append(sources, name)
+			}
+			return nil
+		})
+		if err != nil {
+			return "", "", err
+		}
+		if len(sources) != 1 {
+			return "", "", fmt.Errorf("expected one source file in %s, found %d", dir, len(sources))
+		}
+		lang, err := SourceLanguage(sources[0])
+		if err != nil {
+			return "", "", err
+		}
+		source, err := readPackageFile(pkg, sources[0])
+		return lang, source, err
+	}
+	return "", "", fs.ErrNotExist
+}
+
+// kattisSolution picks the first single-file accepted submission as the reference solution
+func kattisSolution(pkg fs.FS) (string, string, error) {
+	entries, err := fs.ReadDir(pkg, "submissions/accepted")
+	if err != nil {
+		return "", "", err
+	}
+	for _, entry := range entries {
+		if entry.IsDir() {
+			continue
+		}
+		name := path.Join("submissions/accepted", entry.Name())
+		lang, err := SourceLanguage(name)
+		if err != nil {
+			continue
+		}
+		source, err := readPackageFile(pkg, name)
+		return lang, source, err
+	}
+	return "", "", fs.ErrNotExist
+}
+
+// kattisName reads a problem name that is either a string or a map of language to string
+func kattisName(node yaml.Node) string {
+	switch node.Kind {
+	case yaml.ScalarNode:
+		return node.Value
+	case yaml.MappingNode:
+		for i := 0; i+1 < len(node.Content); i += 2 {
+			if node.Content[i].Value == "en" {
+				return node.Content[i+1].Value
+			}
+		}
+		if len(node.Content) >= 2 {
+			return node.Content[1].Value
+		}
+	}
+	return ""
+}
+
+func contains(list []string, item string) bool {
+	for _, v := range list {
+		if v == item {
+			return true
+		}
+	}
+	return false
+}
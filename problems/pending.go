@@ -0,0 +1,71 @@
+package problems
+
+import (
+	"os"
+	"path/filepath"
+
+	"xcodeengine/model"
+)
+
+// OnReload sets a function to run in its own goroutine after every reload, and once
+// right away. The engine uses it to validate the problems pending validation.
+func (s *Store) OnReload(fn func()) {
+	s.mu.Lock()
+	s.onReload = fn
+	s.mu.Unlock()
+	go fn()
+}
+
+// Pending returns the problems held back until their validator accepts their inputs,
+// leaving out those whose current inputs it has already turned down
+func (s *Store) Pending() []model.Problem {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	var pending []model.Problem
+	for _, p := range s.problems {
+		if p.PendingValidation && !s.rejected[inputsDigest(&p)] {
+			pending = append(pending, p)
+		}
+	}
+	return pending
+}
+
+// RecordValidation records the outcome of a validator run over a problem's inputs.
+// Accepted inputs are remembered across restarts and release the problem if it is held
+// back with those inputs; turned-down ones keep it held back until they change.
+func (s *Store) RecordValidation(problem *model.Problem, valid bool) error {
+	if s.dir == "" || problem.Validator == nil {
+		return nil
+	}
+	sum := inputsDigest(problem)
+	if !valid {
+		s.mu.Lock()
+		s.rejected[sum] = true
+		s.mu.Unlock()
+		return nil
+	}
+
+	dir := filepath.Join(s.dir, validatedCacheDir)
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return err
+	}
+	if err := os.WriteFile(filepath.Join(dir, sum), nil, 0o644); err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	release := func(p *model.Problem) {
+		if p.ID == problem.ID && p.PendingValidation && inputsDigest(p) == sum {
+			p.Draft, p.PendingValidation = false, false
+		}
+	}
+	for i := range s.problems {
+		release(&s.problems[i])
+	}
+	for folder, p := range s.folders {
+		release(&p)
+		s.folders[folder] = p
+	}
+	return nil
+}
@@ -0,0 +1,305 @@
+package problems
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"io/fs"
+	"path"
+	"strings"
+
+	"xcodeengine/model"
+)
+
+// polygonProblem is the part of a Polygon problem.xml the importer reads
+type polygonProblem struct {
+	ShortName string `xml:"short-name,attr"`
+	Names     []struct {
+		Language string `xml:"language,attr"`
+		Value    string `xml:"value,attr"`
+	} `xml:"names>name"`
+	Statements []polygonStatementRef `xml:"statements>statement"`
+	Testsets   []polygonTestset      `xml:"judging>testset"`
+	Checker    *struct {
+		Name   string        `xml:"name,attr"`
+		Source polygonSource `xml:"source"`
+	} `xml:"assets>checker"`
+	Interactor *struct {
+		Source polygonSource `xml:"source"`
+	} `xml:"assets>interactor"`
+	Validators []struct {
+		Source polygonSource `xml:"source"`
+	} `xml:"assets>validators>validator"`
+	Solutions []struct {
+		Tag    string        `xml:"tag,attr"`
+		Source polygonSource `xml:"source"`
+	} `xml:"assets>solutions>solution"`
+}
+
+type polygonTestset struct {
+	Name          string `xml:"name,attr"`
+	TimeLimit     int    `xml:"time-limit"`   // milliseconds
+	MemoryLimit   int64  `xml:"memory-limit"` // bytes
+	InputPattern  string `xml:"input-path-pattern"`
+	AnswerPattern string `xml:"answer-path-pattern"`
+	Tests         []struct {
+		Sample bool    `xml:"sample,attr"`
+		Points float64 `xml:"points,attr"`
+		Group  string  `xml:"group,attr"`
+	} `xml:"tests>test"`
+	Groups []struct {
+		Name         string  `xml:"name,attr"`
+		Points       float64 `xml:"points,attr"`
+		PointsPolicy string  `xml:"points-policy,attr"`
+		Dependencies []struct {
+			Group string `xml:"group,attr"`
+		} `xml:"dependencies>dependency"`
+	} `xml:"groups>group"`
+}
+
+type polygonStatementRef struct {
+	Language string `xml:"language,attr"`
+}
+
+type polygonSource struct {
+	Path string `xml:"path,attr"`
+}
+
+// polygonStatement is statements/<language>/problem-properties.json
+type polygonStatement struct {
+	Name   string `json:"name"`
+	Legend string `json:"legend"`
+	Input  string `json:"input"`
+	Output string `json:"output"`
+	Notes  string `json:"notes"`
+}
+
+// polygonComparators replaces the standard testlib checkers that a comparator covers,
+// which saves a sandbox run per test
+var polygonComparators = map[string]model.Comparator{
+	"std::wcmp.cpp":  {Mode: model.CompareTokens},
+	"std::ncmp.cpp":  {Mode: model.CompareTokens},
+	"std::hcmp.cpp":  {Mode: model.CompareTokens},
+	"std::fcmp.cpp":  {Mode: model.CompareExact},
+	"std::rcmp4.cpp": {Mode: model.CompareFloat, Epsilon: 1e-4},
+	"std::rcmp6.cpp": {Mode: model.CompareFloat, Epsilon: 1e-6},
+	"std::rcmp9.cpp": {Mode: model.CompareFloat, Epsilon: 1e-9},
+}
+
+// importPolygon reads a full Polygon package, which carries its generated tests
+func importPolygon(pkg fs.FS) (*model.Problem, []string, error) {
+	data, err := fs.ReadFile(pkg, "problem.xml")
+	if err != nil {
+		return nil, nil, err
+	}
+	var desc polygonProblem
+	if err := xml.Unmarshal(data, &desc); err != nil {
+		return nil, nil, fmt.Errorf("problem.xml: %v", err)
+	}
+
+	var warnings []string
+	problem := &model.Problem{ID: desc.ShortName}
+
+	language := "english"
+	if len(desc.Statements) > 0 && !hasPolygonLanguage(desc.Statements, language) {
+		language = desc.Statements[0].Language
+	}
+	for _, name := range desc.Names {
+		if name.Language == language || problem.Title == "" {
+			problem.Title = name.Value
+		}
+	}
+	statement, err := polygonStatementFor(pkg, language)
+	if err != nil {
+		return nil, nil, err
+	}
+	if problem.Title == "" {
+		problem.Title = statement.Name
+	}
+	problem.Description = section("Task", statement.Legend) + section("Output", statement.Output) + section("Notes", statement.Notes)
+	problem.InputFormat = strings.TrimSpace(statement.Input)
+
+	if len(desc.Testsets) == 0 {
+		return nil, nil, fmt.Errorf("no testsets")
+	}
+	testset := desc.Testsets[0]
+	for _, ts := range desc.Testsets {
+		if ts.Name == "tests" {
+			testset = ts
+		}
+	}
+	if len(desc.Testsets) > 1 {
+		warnings = append(warnings, fmt.Sprintf("only testset %q was imported", testset.Name))
+	}
+	problem.TimeLimit = testset.TimeLimit
+	problem.MemoryLimit = int(testset.MemoryLimit >> 20)
+
+	if desc.Interactor != nil {
+		source, err := readPackageFile(pkg, desc.Interactor.Source.Path)
+		if err != nil {
+			return nil, nil, fmt.Errorf("interactor: %v", err)
+		}
+		lang, err := SourceLanguage(desc.Interactor.Source.Path)
+		if err != nil {
+			return nil, nil, fmt.Errorf("interactor: %v", err)
+		}
+		problem.Interactor = &model.Interactor{Language: lang, Source: source}
+	}
+
+	for i, test := range testset.Tests {
+		input, err := readPackageFile(pkg, fmt.Sprintf(testset.InputPattern, i+1))
+		if err != nil {
+			return nil, nil, fmt.Errorf("test %d: %v (export a full package to include generated tests)", i+1, err)
+		}
+		answer, err := readOptional(pkg, fmt.Sprintf(testset.AnswerPattern, i+1))
+		if err != nil {
+			return nil, nil, fmt.Errorf("test %d: %v", i+1, err)
+		}
+		name := fmt.Sprintf("Test #%d", i+1)
+		if test.Sample {
+			name = fmt.Sprintf("Sample #%d", i+1)
+		}
+		problem.TestCases = append(problem.TestCases, model.TestCase{
+			Name:           name,
+			Input:          input,
+			ExpectedOutput: answer,
+			Hidden:         !test.Sample,
+		})
+	}
+
+	if desc.Checker != nil && desc.Interactor == nil {
+		if cmp, ok := polygonComparators[desc.Checker.Name]; ok {
+			problem.Comparator = cmp
+		} else {
+			source, err := readPackageFile(pkg, desc.Checker.Source.Path)
+			if err != nil {
+				return nil, nil, fmt.Errorf("checker: %v", err)
+			}
+			lang, err := SourceLanguage(desc.Checker.Source.Path)
+			if err != nil {
+				return nil, nil, fmt.Errorf("checker: %v", err)
+			}
+			problem.Checker = &model.Checker{Language: lang, Source: source}
+		}
+	}
+
+	if len(desc.Validators) > 0 {
+		source, err := readPackageFile(pkg, desc.Validators[0].Source.Path)
+		if err != nil {
+			return nil, nil, fmt.Errorf("validator: %v", err)
+		}
+		lang, err := SourceLanguage(desc.Validators[0].Source.Path)
+		if err != nil {
+			return nil, nil, fmt.Errorf("validator: %v", err)
+		}
+		problem.Validator = &model.Validator{Language: lang, Source: source}
+		if len(desc.Validators) > 1 {
+			warnings = append(warnings, "only the first validator was imported")
+		}
+	}
+
+	for _, solution := range desc.Solutions {
+		if solution.Tag != "main" {
+			continue
+		}
+		source, err := readPackageFile(pkg, solution.Source.Path)
+		if err != nil {
+			return nil, nil, fmt.Errorf("main solution: %v", err)
+		}
+		if lang, err := SourceLanguage(solution.Source.Path); err == nil {
+			problem.Solution = &model.Solution{Language: lang, Source: source}
+		} else {
+			warnings = append(warnings, fmt.Sprintf("main solution left out: %v", err))
+		}
+	}
+
+	subtasks, subtaskWarnings := polygonSubtasks(testset)
+	problem.Subtasks = subtasks
+	warnings = append(warnings, subtaskWarnings...)
+
+	return problem, warnings, nil
+}
+
+// polygonSubtasks turns the point groups of a testset into subtasks. A complete-group
+// group scores all or nothing; an each-test group shares its points between its tests.
+func polygonSubtasks(testset polygonTestset) ([]model.Subtask, []string) {
+	if len(testset.Groups) == 0 {
+		return nil, nil
+	}
+	var warnings []string
+	subtasks := make([]model.Subtask, 0, len(testset.Groups))
+	for _, group := range testset.Groups {
+		subtask := model.Subtask{Name: group.Name, Scoring: model.ScoringAll}
+		var testPoints []float64
+		for i, test := range testset.Tests {
+			if test.Group == group.Name {
+				subtask.Tests = append(subtask.Tests, i+1)
+				testPoints = append(testPoints, test.Points)
+				subtask.Score += test.Points
+			}
+		}
+		if len(subtask.Tests) == 0 {
+			warnings = append(warnings, fmt.Sprintf("group %s has no tests and was left out", group.Name))
+			continue
+		}
+		if group.Points > 0 {
+			subtask.Score = group.Points
+		}
+		if group.PointsPolicy == "each-test" {
+			subtask.Scoring = model.ScoringSum
+			for _, p := range testPoints[1:] {
+				if p != testPoints[0] {
+					warnings = append(warnings, fmt.Sprintf("group %s gives its tests different points; they now share the score equally", group.Name))
+					break
+				}
+			}
+		}
+		for _, dep := range group.Dependencies {
+			subtask.DependsOn = append(subtask.DependsOn, dep.Group)
+		}
+		subtasks = append(subtasks, subtask)
+	}
+	return subtasks, warnings
+}
+
+// polygonStatementFor reads a statement's sections, from problem-properties.json when
+// the package has it and from the section files otherwise
+func polygonStatementFor(pkg fs.FS, language string) (polygonStatement, error) {
+	var statement polygonStatement
+	properties, err := readOptional(pkg, path.Join("statements", language, "problem-properties.json"))
+	if err != nil {
+		return statement, err
+	}
+	if properties != "" {
+		if err := json.Unmarshal([]byte(properties), &statement); err != nil {
+			return statement, fmt.Errorf("problem-properties.json: %v", err)
+		}
+		return statement, nil
+	}
+
+	sections := map[string]*string{
+		"name.tex":   &statement.Name,
+		"legend.tex": &statement.Legend,
+		"input.tex":  &statement.Input,
+		"output.tex": &statement.Output,
+		"notes.tex":  &statement.Notes,
+	}
+	for file, field := range sections {
+		content, err := readOptional(pkg, path.Join("statement-sections", language, file))
+		if err != nil {
+			return statement, err
+		}
+		*field = strings.TrimSpace(content)
+	}
+	return statement, nil
+}
+
+// hasPolygonLanguage reports whether the package has a statement in a language
+func hasPolygonLanguage(statements []polygonStatementRef, language string) bool {
+	for _, s := range statements {
+		if s.Language == language {
+			return true
+		}
+	}
+	return false
+}
@@ -9,9 +9,12 @@ var problemSet = []model.Problem{
 		Description: "### Task\nRead two integers and output their sum.\n\n### Notes\n- Input fits in 32-bit signed integer.\n- Output should include a newline.",
 		InputFormat: "Two integers A and B separated by space.",
 		Constraints: "`0 ≤ A, B ≤ 10^9`",
+		TimeLimit:   1000,
+		MemoryLimit: 256,
 		TestCases: []model.TestCase{
 			{Name: "Sample #1", Input: "2 3\n", ExpectedOutput: "5\n"},
 			{Name: "Sample #2", Input: "100 250\n", ExpectedOutput: "350\n"},
+			{Name: "Large", Input: "1000000000 1000000000\n", ExpectedOutput: "2000000000\n", Hidden: true},
 		},
 	},
 	{
@@ -20,6 +23,7 @@ var problemSet = []model.Problem{
 		Description: "### Task\nPrint numbers from 1 to N.\n- Multiples of 3 => `Fizz`\n- Multiples of 5 => `Buzz`\n- Multiples of 15 => `FizzBuzz`",
 		InputFormat: "Single integer N.",
 		Constraints: "`1 ≤ N ≤ 10^3`",
+		Validator:   &model.Validator{Language: "cpp", Source: fizzBuzzValidator},
 		TestCases: []model.TestCase{
 			{
 				Name:           "Sample #1",
@@ -42,6 +46,12 @@ var problemSet = []model.Problem{
 		TestCases: []model.TestCase{
 			{Name: "Sample #1", Input: "{}[]()\n", ExpectedOutput: "YES\n"},
 			{Name: "Sample #2", Input: "{[}]\n", ExpectedOutput: "NO\n"},
+			{Name: "Nested", Input: "([{}])[]\n", ExpectedOutput: "YES\n", Hidden: true},
+			{Name: "Unclosed", Input: "(((\n", ExpectedOutput: "NO\n", Hidden: true},
+		},
+		Subtasks: []model.Subtask{
+			{Name: "Samples", Score: 20, Tests: []int{1, 2}},
+			{Name: "Full", Score: 80, Scoring: model.ScoringSum, Tests: []int{1, 2, 3, 4}, DependsOn: []string{"Samples"}},
 		},
 	},
 	{
@@ -50,17 +60,55 @@ var problemSet = []model.Problem{
 		Description: "### Task\nGiven an array and a target, determine if any pair sums to the target.",
 		InputFormat: "First line: N and target. Second line: N integers.",
 		Constraints: "`2 ≤ N ≤ 10^5` (values fit in 32-bit signed int)",
+		TimeLimit:   1000,
+		MemoryLimit: 256,
+		LimitMultipliers: map[string]model.LimitMultiplier{
+			"java":   {Time: 2, Memory: 2},
+			"python": {Time: 3},
+			"js":     {Time: 2},
+		},
+		DefaultFlags: map[string][]string{
+			"c":   {"-O2"},
+			"cpp": {"-O2"},
+		},
 		TestCases: []model.TestCase{
 			{Name: "Sample #1", Input: "4 9\n2 7 11 15\n", ExpectedOutput: "YES\n"},
 			{Name: "Sample #2", Input: "3 10\n1 2 3\n", ExpectedOutput: "NO\n"},
 		},
 	},
+	{
+		ID:          "pair-with-sum",
+		Title:       "Pair With Sum",
+		Description: "### Task\nGiven an array and a target, print the 1-based indices `i < j` of any pair with `a[i] + a[j] = target`, or `-1` if there is none.\n\n### Notes\n- Any valid pair is accepted.",
+		InputFormat: "First line: N and target. Second line: N integers.",
+		Constraints: "`2 ≤ N ≤ 10^5` (values fit in 32-bit signed int)",
+		Checker:     &model.Checker{Language: "cpp", Source: pairWithSumChecker},
+		TestCases: []model.TestCase{
+			{Name: "Sample #1", Input: "4 9\n2 7 11 15\n", ExpectedOutput: "1 2\n"},
+			{Name: "Sample #2", Input: "5 6\n1 5 3 3 2\n", ExpectedOutput: "1 2\n"},
+			{Name: "Sample #3", Input: "3 10\n1 2 3\n", ExpectedOutput: "-1\n"},
+		},
+	},
+	{
+		ID:          "guess-the-number",
+		Title:       "Guess The Number",
+		Description: "### Task\nThe judge picked a hidden integer X in `[1, N]`. Print a guess on its own line and flush; the judge answers `<` if X is smaller, `>` if X is larger, or `=` when you found it.\n\n### Notes\n- At most 30 guesses are allowed.\n- Remember to flush after every guess.",
+		InputFormat: "First line: N. Then the judge's answers, one per guess.",
+		Constraints: "`1 ≤ N ≤ 10^9`",
+		Interactor:  &model.Interactor{Language: "cpp", Source: guessTheNumberInteractor},
+		TestCases: []model.TestCase{
+			{Name: "Sample #1", Input: "10 7\n"},
+			{Name: "Sample #2", Input: "1000000000 1\n"},
+			{Name: "Sample #3", Input: "1000000000 1000000000\n"},
+		},
+	},
 	{
 		ID:          "matrix-trace",
 		Title:       "Matrix Trace",
 		Description: "### Task\nCompute the trace of an `N x N` matrix (sum of diagonal elements).",
 		InputFormat: "First line: N. Next N lines: N integers each.",
 		Constraints: "`1 ≤ N ≤ 200`",
+		Comparator:  model.Comparator{Mode: model.CompareTokens},
 		TestCases: []model.TestCase{
 			{
 				Name:           "Sample #1",
@@ -74,18 +122,83 @@ var problemSet = []model.Problem{
 			},
 		},
 	},
+	{
+		ID:          "running-sum",
+		Title:       "Running Sum",
+		Description: "### Task\nWrite `runningSum(nums)`, which returns the running sums of `nums`: element `i` of the result is `nums[0] + ... + nums[i]`.",
+		InputFormat: "The function receives `nums`, a list of integers.",
+		Constraints: "`0 ≤ len(nums) ≤ 1000`, `-10^6 ≤ nums[i] ≤ 10^6`",
+		Function: &model.Function{
+			Name:    "runningSum",
+			Params:  []model.Parameter{{Name: "nums", Type: model.TypeInt + "[]"}},
+			Returns: model.TypeInt + "[]",
+		},
+		TestCases: []model.TestCase{
+			{Name: "Sample #1", Input: "[[1, 2, 3, 4]]", ExpectedOutput: "[1, 3, 6, 10]"},
+			{Name: "Sample #2", Input: "[[3, -1, 0, -2]]", ExpectedOutput: "[3, 2, 2, 0]"},
+			{Name: "Empty", Input: "[[]]", ExpectedOutput: "[]", Hidden: true},
+			{Name: "Single", Input: "[[1000000]]", ExpectedOutput: "[1000000]", Hidden: true},
+		},
+	},
 }
 
-func ListProblems() []model.Problem {
-	return problemSet
+// fizzBuzzValidator checks that an input is a single N within the constraints
+const fizzBuzzValidator = `#include "testlib.h"
+
+int main(int argc, char* argv[]) {
+    registerValidation(argc, argv);
+    inf.readInt(1, 1000, "N");
+    inf.readEoln();
+    inf.readEof();
+    return 0;
 }
+`
+
+// pairWithSumChecker accepts any pair of indices whose values add up to the target
+const pairWithSumChecker = `#include "testlib.h"
+
+int main(int argc, char* argv[]) {
+    registerTestlibCmd(argc, argv);
+
+    int n = inf.readInt();
+    long long target = inf.readLong();
+    std::vector<long long> a(n);
+    for (auto& x : a) x = inf.readLong();
+
+    int expected = ans.readInt();
+    int i = ouf.readInt(-1, n, "i");
+    if (i == -1) {
+        if (expected != -1) quitf(_wa, "a pair exists but participant printed -1");
+        quitf(_ok, "no pair");
+    }
+    int j = ouf.readInt(1, n, "j");
+    if (i < 1 || i >= j) quitf(_wa, "indices must satisfy 1 <= i < j, got %d %d", i, j);
+    if (a[i - 1] + a[j - 1] != target) quitf(_wa, "a[%d] + a[%d] != %lld", i, j, target);
+    // A valid pair on a test whose answer is -1 means the jury answer is wrong
+    if (expected == -1) quitf(_fail, "participant found a pair %d %d but the answer is -1", i, j);
+    quitf(_ok, "pair %d %d", i, j);
+}
+`
+
+// guessTheNumberInteractor reads N and the hidden number from the test and answers
+// up to 30 guesses
+const guessTheNumberInteractor = `#include "testlib.h"
+
+int main(int argc, char* argv[]) {
+    registerInteraction(argc, argv);
+
+    int n = inf.readInt();
+    int x = inf.readInt();
+    std::cout << n << std::endl;
 
-func GetProblem(id string) (*model.Problem, bool) {
-	for _, p := range problemSet {
-		if p.ID == id {
-			cp := p
-			return &cp, true
-		}
-	}
-	return nil, false
+    for (int guesses = 1; guesses <= 30; guesses++) {
+        int guess = ouf.readInt(1, n, "guess");
+        if (guess == x) {
+            std::cout << "=" << std::endl;
+            quitf(_ok, "found %d in %d guesses", x, guesses);
+        }
+        std::cout << (x < guess ? "<" : ">") << std::endl;
+    }
+    quitf(_wa, "no correct guess in 30 tries");
 }
+`
@@ -0,0 +1,207 @@
+package problems
+
+import (
+	"fmt"
+	"log"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"xcodeengine/model"
+)
+
+// Store holds a problem set. A store loaded from a directory can be reloaded while
+// the engine runs; lookups always see a complete set.
+type Store struct {
+	mu       sync.RWMutex
+	problems []model.Problem
+	dir      string
+	// folders maps each loaded folder to the problem it holds, so that a folder that
+	// breaks during an edit keeps serving its last good version
+	folders map[string]model.Problem
+	version uint64
+	heads   map[string]versionHead
+	// writes serialises edits, each of which writes a folder and reloads
+	writes sync.Mutex
+	// rejected holds the digests of inputs that a problem's validator turned down
+	rejected map[string]bool
+	onReload func()
+}
+
+// active is the store behind ListProblems and GetProblem
+var active atomic.Pointer[Store]
+
+func init() {
+	for i := range problemSet {
+		problemSet[i].Version = 1
+		if err := Validate(&problemSet[i]); err != nil {
+			panic(fmt.Sprintf("built-in problem %s: %v", problemSet[i].ID, err))
+		}
+	}
+	active.Store(&Store{problems: problemSet})
+}
+
+// Use makes store the one that ListProblems and GetProblem read from
+func Use(store *Store) {
+	active.Store(store)
+}
+
+// LoadStore loads every problem folder under dir. Folders that fail validation are
+// logged and left out.
+func LoadStore(dir string) (*Store, error) {
+	store := &Store{dir: dir, folders: make(map[string]model.Problem), heads: make(map[string]versionHead), rejected: make(map[string]bool)}
+	if err := store.Reload(); err != nil {
+		return nil, err
+	}
+	return store, nil
+}
+
+// Reload reads the directory again and swaps in the new set. A folder that no longer
+// loads keeps its previous version, and the error is logged.
+func (s *Store) Reload() error {
+	if s.dir == "" {
+		return nil
+	}
+	version, err := fingerprint(s.dir)
+	if err != nil {
+		return fmt.Errorf("failed to scan problems directory: %v", err)
+	}
+	loaded, errs, err := loadDir(s.dir)
+	if err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	folders := loaded
+	for folder, err := range errs {
+		log.Printf("Problem %s failed to load: %v", folder, err)
+		if previous, ok := s.folders[folder]; ok {
+			log.Printf("Keeping the previous version of problem %s", previous.ID)
+			folders[folder] = previous
+		}
+	}
+
+	set := make([]model.Problem, 0, len(folders))
+	seen := make(map[string]string, len(folders))
+	names := make([]string, 0, len(folders))
+	for folder := range folders {
+		names = append(names, folder)
+	}
+	sort.Strings(names)
+	for _, folder := range names {
+		p := folders[folder]
+		if other, ok := seen[p.ID]; ok {
+			log.Printf("Problem %s in folder %s duplicates folder %s; ignoring it", p.ID, folder, other)
+			delete(folders, folder)
+			continue
+		}
+		seen[p.ID] = folder
+		if err := s.recordVersion(&p); err != nil {
+			log.Printf("Problem %s: %v", p.ID, err)
+		}
+		// Published in its folder, but not live until the validator accepts its inputs
+		if !p.Draft && !s.inputsValidated(&p) {
+			p.Draft, p.PendingValidation = true, true
+		}
+		folders[folder] = p
+		set = append(set, p)
+	}
+
+	s.problems = set
+	s.folders = folders
+	s.version = version
+	log.Printf("Loaded %d problems from %s", len(set), s.dir)
+	if s.onReload != nil {
+		go s.onReload()
+	}
+	return nil
+}
+
+// Watch polls the directory every interval and reloads the store when any file in
+// it changes, until stop is closed
+func (s *Store) Watch(interval time.Duration, stop <-chan struct{}) {
+	if s.dir == "" || interval <= 0 {
+		return
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+			version, err := fingerprint(s.dir)
+			if err != nil {
+				log.Printf("Failed to scan problems directory: %v", err)
+				continue
+			}
+			s.mu.RLock()
+			changed := version != s.version
+			s.mu.RUnlock()
+			if !changed {
+				continue
+			}
+			if err := s.Reload(); err != nil {
+				log.Printf("Failed to reload problems: %v", err)
+			}
+		}
+	}
+}
+
+// List returns every problem open to participants as they may see it, without
+// hidden tests
+func (s *Store) List() []model.Problem {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	list := make([]model.Problem, 0, len(s.problems))
+	for _, p := range s.problems {
+		if !p.Archived && !p.Draft {
+			list = append(list, p.Public())
+		}
+	}
+	return list
+}
+
+// All returns every problem in full, archived ones and drafts included
+func (s *Store) All() []model.Problem {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return append([]model.Problem(nil), s.problems...)
+}
+
+// Get returns a copy of a problem, hidden tests included
+func (s *Store) Get(id string) (*model.Problem, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	for _, p := range s.problems {
+		if p.ID == id {
+			cp := p
+			return &cp, true
+		}
+	}
+	return nil, false
+}
+
+// ListProblems returns every problem open to participants as they may see it, without
+// hidden tests
+func ListProblems() []model.Problem {
+	return active.Load().List()
+}
+
+// GetProblem returns a copy of a problem from the active store, hidden tests included
+func GetProblem(id string) (*model.Problem, bool) {
+	return active.Load().Get(id)
+}
+
+// Active returns the store behind ListProblems and GetProblem
+func Active() *Store {
+	return active.Load()
+}
+
+// Dir returns the directory the store loads from, or "" for the built-in problems
+func (s *Store) Dir() string {
+	return s.dir
+}
@@ -0,0 +1,144 @@
+package problems
+
+import (
+	"fmt"
+	"regexp"
+
+	"xcodeengine/executor"
+	"xcodeengine/model"
+)
+
+// validID matches problem IDs, which double as folder names and URL segments
+var validID = regexp.MustCompile(`^[a-z0-9][a-z0-9-]*$`)
+
+// validGenerator matches generator names, which name their source files
+var validGenerator = regexp.MustCompile(`^[A-Za-z0-9_][A-Za-z0-9_-]*$`)
+
+// Validate checks that a problem can be judged: it needs an ID, a title and tests, a
+// known comparator and judging mode, and subtasks that refer to its tests
+func Validate(problem *model.Problem) error {
+	if !validID.MatchString(problem.ID) {
+		return fmt.Errorf("invalid problem id: %q", problem.ID)
+	}
+	if problem.Title == "" {
+		return fmt.Errorf("problem %s has no title", problem.ID)
+	}
+	if testCount(problem) == 0 {
+		return fmt.Errorf("problem %s has no tests", problem.ID)
+	}
+	if problem.TimeLimit < 0 || problem.MemoryLimit < 0 {
+		return fmt.Errorf("problem %s has a negative limit", problem.ID)
+	}
+	switch problem.Comparator.Mode {
+	case "", model.CompareExact, model.CompareTokens, model.CompareFloat, model.CompareCaseInsensitive, model.CompareUnorderedLines, model.CompareValues:
+	default:
+		return fmt.Errorf("unknown comparator mode: %s", problem.Comparator.Mode)
+	}
+	switch problem.JudgeMode {
+	case "", model.JudgeModeFull, model.JudgeModeICPC:
+	default:
+		return fmt.Errorf("unknown judge mode: %s", problem.JudgeMode)
+	}
+	if problem.Checker != nil && (problem.Checker.Language == "" || problem.Checker.Source == "") {
+		return fmt.Errorf("problem %s checker needs a language and source", problem.ID)
+	}
+	if problem.Interactor != nil && (problem.Interactor.Language == "" || problem.Interactor.Source == "") {
+		return fmt.Errorf("problem %s interactor needs a language and source", problem.ID)
+	}
+	if problem.Validator != nil && (problem.Validator.Language == "" || problem.Validator.Source == "") {
+		return fmt.Errorf("problem %s validator needs a language and source", problem.ID)
+	}
+	if problem.Solution != nil && (problem.Solution.Language == "" || problem.Solution.Source == "") {
+		return fmt.Errorf("problem %s solution needs a language and source", problem.ID)
+	}
+	if err := validateScript(problem); err != nil {
+		return err
+	}
+	if problem.Function != nil {
+		if err := validateFunction(problem); err != nil {
+			return fmt.Errorf("problem %s: %w", problem.ID, err)
+		}
+	}
+	return validateSubtasks(problem)
+}
+
+// validateScript checks that every test script line runs a defined generator with
+// arguments that can be passed to it
+func validateScript(problem *model.Problem) error {
+	generators := make(map[string]bool, len(problem.Generators))
+	for _, g := range problem.Generators {
+		if !validGenerator.MatchString(g.Name) {
+			return fmt.Errorf("invalid generator name: %q", g.Name)
+		}
+		if generators[g.Name] {
+			return fmt.Errorf("duplicate generator: %s", g.Name)
+		}
+		if g.Language == "" || g.Source == "" {
+			return fmt.Errorf("generator %s needs a language and source", g.Name)
+		}
+		generators[g.Name] = true
+	}
+	for i, line := range problem.TestScript {
+		if !generators[line.Generator] {
+			return fmt.Errorf("test script line %d uses unknown generator %q", i+1, line.Generator)
+		}
+		for _, arg := range line.Args {
+			if err := executor.ValidateArg(arg); err != nil {
+				return fmt.Errorf("test script line %d: %v", i+1, err)
+			}
+		}
+	}
+	return nil
+}
+
+// testCount returns how many tests a problem has with its test script materialised
+func testCount(problem *model.Problem) int {
+	n := len(problem.TestScript)
+	for _, tc := range problem.TestCases {
+		if !tc.Generated {
+			n++
+		}
+	}
+	return n
+}
+
+// Materialised reports whether a problem's generated tests match its test script
+func Materialised(problem *model.Problem) bool {
+	return len(problem.TestCases) == testCount(problem)
+}
+
+// validateSubtasks checks subtask definitions against the problem's tests; a
+// subtask may only depend on subtasks listed before it
+func validateSubtasks(problem *model.Problem) error {
+	// Subtasks may refer to tests the test script has yet to materialise
+	tests := max(len(problem.TestCases), testCount(problem))
+	seen := make(map[string]bool, len(problem.Subtasks))
+	for _, subtask := range problem.Subtasks {
+		if subtask.Name == "" {
+			return fmt.Errorf("subtask requires a name")
+		}
+		if seen[subtask.Name] {
+			return fmt.Errorf("duplicate subtask: %s", subtask.Name)
+		}
+		switch subtask.Scoring {
+		case "", model.ScoringAll, model.ScoringMin, model.ScoringSum:
+		default:
+			return fmt.Errorf("subtask %s has unknown scoring rule: %s", subtask.Name, subtask.Scoring)
+		}
+		if len(subtask.Tests) == 0 {
+			return fmt.Errorf("subtask %s has no tests", subtask.Name)
+		}
+		for _, test := range subtask.Tests {
+			if test < 1 || test > tests {
+				return fmt.Errorf("subtask %s refers to missing test %d", subtask.Name, test)
+			}
+		}
+		for _, dep := range subtask.DependsOn {
+			if !seen[dep] {
+				return fmt.Errorf("subtask %s depends on %s, which is not an earlier subtask", subtask.Name, dep)
+			}
+		}
+		seen[subtask.Name] = true
+	}
+	return nil
+}
@@ -0,0 +1,195 @@
+package problems
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"xcodeengine/model"
+)
+
+// versionsDir holds the version history of every problem, inside the problems directory
+const versionsDir = ".versions"
+
+// ErrVersionNotFound is returned for a problem version that was never recorded
+var ErrVersionNotFound = errors.New("problem version not found")
+
+// VersionInfo describes one recorded version of a problem
+type VersionInfo struct {
+	Version int       `json:"version"`
+	Created time.Time `json:"created"`
+}
+
+// versionFile is one immutable version of a problem, kept as <id>/<version>.json
+type versionFile struct {
+	VersionInfo
+	Digest  string                `json:"digest"`
+	Problem model.ProblemDocument `json:"problem"`
+}
+
+// versionHead is the newest version recorded for a problem
+type versionHead struct {
+	version int
+	digest  string
+}
+
+// digest identifies a problem's content, whatever version the store gave it
+func digest(p model.Problem) string {
+	p.Version = 0
+	data, _ := json.Marshal(p.Document())
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+// recordVersion gives a problem its version number. Content that differs from the
+// newest version becomes a new version, written once and never changed. The caller
+// holds s.mu.
+func (s *Store) recordVersion(p *model.Problem) error {
+	head, ok := s.heads[p.ID]
+	if !ok {
+		var err error
+		if head, err = s.readHead(p.ID); err != nil {
+			return err
+		}
+	}
+
+	sum := digest(*p)
+	if head.version > 0 && head.digest == sum {
+		p.Version = head.version
+		s.heads[p.ID] = head
+		return nil
+	}
+
+	next := head.version + 1
+	p.Version = next
+	record := versionFile{
+		VersionInfo: VersionInfo{Version: next, Created: time.Now().UTC()},
+		Digest:      sum,
+		Problem:     p.Document(),
+	}
+	data, err := json.MarshalIndent(record, "", "  ")
+	if err != nil {
+		return err
+	}
+	dir := filepath.Join(s.dir, versionsDir, p.ID)
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return err
+	}
+	file, err := os.OpenFile(filepath.Join(dir, strconv.Itoa(next)+".json"), os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0o444)
+	if err != nil {
+		return fmt.Errorf("failed to record version %d of %s: %v", next, p.ID, err)
+	}
+	defer file.Close()
+	if _, err := file.Write(data); err != nil {
+		return fmt.Errorf("failed to record version %d of %s: %v", next, p.ID, err)
+	}
+
+	s.heads[p.ID] = versionHead{version: next, digest: sum}
+	return nil
+}
+
+// readHead finds the newest recorded version of a problem; a problem with no history
+// has version 0
+func (s *Store) readHead(id string) (versionHead, error) {
+	numbers, err := s.versionNumbers(id)
+	if err != nil || len(numbers) == 0 {
+		return versionHead{}, err
+	}
+	record, err := s.readVersion(id, numbers[len(numbers)-1])
+	if err != nil {
+		return versionHead{}, err
+	}
+	return versionHead{version: record.Version, digest: record.Digest}, nil
+}
+
+// versionNumbers lists the recorded versions of a problem in ascending order
+func (s *Store) versionNumbers(id string) ([]int, error) {
+	entries, err := os.ReadDir(filepath.Join(s.dir, versionsDir, id))
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var numbers []int
+	for _, entry := range entries {
+		n, err := strconv.Atoi(strings.TrimSuffix(entry.Name(), ".json"))
+		if err == nil && n > 0 {
+			numbers = append(numbers, n)
+		}
+	}
+	sort.Ints(numbers)
+	return numbers, nil
+}
+
+func (s *Store) readVersion(id string, version int) (*versionFile, error) {
+	data, err := os.ReadFile(filepath.Join(s.dir, versionsDir, id, strconv.Itoa(version)+".json"))
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, ErrVersionNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	var record versionFile
+	if err := json.Unmarshal(data, &record); err != nil {
+		return nil, fmt.Errorf("version %d of %s is corrupt: %v", version, id, err)
+	}
+	return &record, nil
+}
+
+// Versions lists the recorded versions of a problem, oldest first. They outlive the
+// problem, so results judged against a deleted problem can still be traced.
+func (s *Store) Versions(id string) ([]VersionInfo, error) {
+	if s.dir == "" {
+		if _, ok := s.Get(id); !ok {
+			return nil, ErrProblemNotFound
+		}
+		return []VersionInfo{{Version: 1}}, nil
+	}
+	numbers, err := s.versionNumbers(id)
+	if err != nil {
+		return nil, err
+	}
+	if len(numbers) == 0 {
+		return nil, ErrProblemNotFound
+	}
+	versions := make([]VersionInfo, 0, len(numbers))
+	for _, n := range numbers {
+		record, err := s.readVersion(id, n)
+		if err != nil {
+			return nil, err
+		}
+		versions = append(versions, record.VersionInfo)
+	}
+	return versions, nil
+}
+
+// GetVersion returns one recorded version of a problem
+func (s *Store) GetVersion(id string, version int) (*model.Problem, error) {
+	if s.dir == "" {
+		p, ok := s.Get(id)
+		if !ok {
+			return nil, ErrProblemNotFound
+		}
+		if version != p.Version {
+			return nil, ErrVersionNotFound
+		}
+		return p, nil
+	}
+	record, err := s.readVersion(id, version)
+	if err != nil {
+		return nil, err
+	}
+	p := record.Problem.ToProblem()
+	p.Version = record.Version
+	return &p, nil
+}
@@ -0,0 +1,195 @@
+package problems
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+
+	"xcodeengine/executor"
+	"xcodeengine/model"
+)
+
+// ErrProblemExists is returned when writing a problem whose folder already exists
+var ErrProblemExists = errors.New("problem already exists")
+
+// WriteFolder writes a problem as a folder under dir, in the layout the store loads.
+// Tests are numbered in order. The folder is written aside and renamed into place,
+// so a watching store never sees it half written; an existing folder is only
+// replaced when overwrite is set.
+func WriteFolder(dir string, problem *model.Problem, overwrite bool) error {
+	if err := Validate(problem); err != nil {
+		return err
+	}
+	target := filepath.Join(dir, problem.ID)
+	if _, err := os.Stat(target); err == nil && !overwrite {
+		return fmt.Errorf("%w: %s", ErrProblemExists, problem.ID)
+	}
+
+	staging, err := os.MkdirTemp(dir, ".write-"+problem.ID+"-")
+	if err != nil {
+		return fmt.Errorf("failed to create problem folder: %v", err)
+	}
+	defer os.RemoveAll(staging)
+
+	if err := writeProblemFiles(staging, problem); err != nil {
+		return err
+	}
+	if err := os.Chmod(staging, 0o755); err != nil {
+		return err
+	}
+
+	// Move the old folder aside first; a rename cannot replace a non-empty directory
+	if _, err := os.Stat(target); err == nil {
+		old := staging + ".old"
+		if err := os.Rename(target, old); err != nil {
+			return fmt.Errorf("failed to replace problem folder: %v", err)
+		}
+		defer os.RemoveAll(old)
+	}
+	if err := os.Rename(staging, target); err != nil {
+		return fmt.Errorf("failed to place problem folder: %v", err)
+	}
+	return nil
+}
+
+// writeProblemFiles writes problem.yaml, the statement, judge programs and tests into folder
+func writeProblemFiles(folder string, problem *model.Problem) error {
+	m := manifest{
+		ID:               problem.ID,
+		Title:            problem.Title,
+		InputFormat:      problem.InputFormat,
+		Constraints:      problem.Constraints,
+		TimeLimit:        problem.TimeLimit,
+		MemoryLimit:      problem.MemoryLimit,
+		LimitMultipliers: problem.LimitMultipliers,
+		DefaultFlags:     problem.DefaultFlags,
+		Comparator: comparatorFile{
+			Mode:              problem.Comparator.Mode,
+			Epsilon:           problem.Comparator.Epsilon,
+			PresentationError: problem.Comparator.PresentationError,
+		},
+		JudgeMode: problem.JudgeMode,
+		Archived:  problem.Archived,
+		Draft:     problem.Draft && !problem.PendingValidation,
+	}
+	if problem.Function != nil {
+		m.Function = &functionFile{Name: problem.Function.Name, Returns: problem.Function.Returns}
+		for _, param := range problem.Function.Params {
+			m.Function.Params = append(m.Function.Params, parameterFile{Name: param.Name, Type: param.Type})
+		}
+	}
+	files := map[string]string{statementFile: problem.Description}
+
+	program := func(name, language, source string) (*programFile, error) {
+		file, err := programFileName(name, language)
+		if err != nil {
+			return nil, err
+		}
+		files[file] = source
+		return &programFile{Language: language, Source: file}, nil
+	}
+	var err error
+	if problem.Checker != nil {
+		if m.Checker, err = program("checker", problem.Checker.Language, problem.Checker.Source); err != nil {
+			return err
+		}
+	}
+	if problem.Interactor != nil {
+		if m.Interactor, err = program("interactor", problem.Interactor.Language, problem.Interactor.Source); err != nil {
+			return err
+		}
+	}
+	if problem.Validator != nil {
+		if m.Validator, err = program("validator", problem.Validator.Language, problem.Validator.Source); err != nil {
+			return err
+		}
+	}
+	if problem.Solution != nil {
+		if m.Solution, err = program("solution", problem.Solution.Language, problem.Solution.Source); err != nil {
+			return err
+		}
+	}
+
+	for _, g := range problem.Generators {
+		file, err := programFileName(g.Name, g.Language)
+		if err != nil {
+			return err
+		}
+		file = filepath.Join(generatorsDir, file)
+		files[file] = g.Source
+		m.Generators = append(m.Generators, generatorFile{Name: g.Name, Language: g.Language, Source: file})
+	}
+	for _, line := range problem.TestScript {
+		m.TestScript = append(m.TestScript, scriptLine{
+			Generator: line.Generator,
+			Args:      line.Args,
+			Seed:      line.Seed,
+			Name:      line.Name,
+			Sample:    line.Sample,
+		})
+	}
+
+	m.Samples = []string{}
+	for i, tc := range problem.TestCases {
+		name := strconv.Itoa(i + 1)
+		if !tc.Hidden {
+			m.Samples = append(m.Samples, name)
+		}
+		if tc.Generated {
+			m.Generated = append(m.Generated, name)
+		}
+		if tc.Name != "" && tc.Name != name {
+			if m.TestNames == nil {
+				m.TestNames = make(map[string]string)
+			}
+			m.TestNames[name] = tc.Name
+		}
+		files[filepath.Join(testsDir, name+inputExt)] = tc.Input
+		files[filepath.Join(testsDir, name+outputExt)] = tc.ExpectedOutput
+	}
+	for _, subtask := range problem.Subtasks {
+		m.Subtasks = append(m.Subtasks, subtaskFile{
+			Name:      subtask.Name,
+			Score:     subtask.Score,
+			Scoring:   subtask.Scoring,
+			Tests:     subtask.Tests,
+			DependsOn: subtask.DependsOn,
+		})
+	}
+
+	var data strings.Builder
+	encoder := yaml.NewEncoder(&data)
+	encoder.SetIndent(2)
+	if err := encoder.Encode(&m); err != nil {
+		return err
+	}
+	files[manifestFile] = data.String()
+
+	for name, content := range files {
+		path := filepath.Join(folder, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			return err
+		}
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			return fmt.Errorf("failed to write %s: %v", name, err)
+		}
+	}
+	return nil
+}
+
+// programFileName names a judge program's source after its role, with the extension
+// of its language
+func programFileName(role, language string) (string, error) {
+	base, _, _ := strings.Cut(language, "@")
+	base, _, _ = strings.Cut(executor.NormalizeLanguage(base), "@")
+	config, ok := executor.GetLanguageConfig(base)
+	if !ok {
+		return "", fmt.Errorf("unsupported %s language: %s", role, language)
+	}
+	return role + filepath.Ext(config.SourceFile), nil
+}
@@ -0,0 +1,112 @@
+package service
+
+import (
+	"fmt"
+	"strings"
+
+	"xcodeengine/executor"
+	"xcodeengine/model"
+)
+
+// Files handed to a checker or interactor, relative to its sources
+const (
+	checkerInput  = "input.txt"
+	checkerOutput = "output.txt"
+	checkerAnswer = "answer.txt"
+)
+
+// maxCheckerMessage caps the checker or interactor comment kept in a test result
+const maxCheckerMessage = 1024
+
+// runChecker judges a participant's output with the problem checker and returns
+// the verdict and the checker's comment
+func (s *CompilerService) runChecker(checker *model.Checker, tc model.TestCase, output string) (string, string) {
+	language, version := parseLanguage(checker.Language)
+	config, ok := executor.GetLanguageConfig(language)
+	if !ok {
+		return model.VerdictJudgeFailure, fmt.Sprintf("unsupported checker language: %s", checker.Language)
+	}
+
+	result := s.WorkerPool.ExecuteJobWait(executor.Job{
+		Language: language,
+		Version:  version,
+		Files: []executor.File{
+			{Name: config.SourceFile, Content: checker.Source},
+			{Name: checkerInput, Content: tc.Input},
+			{Name: checkerOutput, Content: output},
+			{Name: checkerAnswer, Content: tc.ExpectedOutput},
+		},
+		Entrypoint: config.SourceFile,
+		Args:       []string{checkerInput, checkerOutput, checkerAnswer},
+	})
+
+	return testlibVerdict(result.ExitCode, result.Error, result.Output)
+}
+
+// testlibVerdict maps the exit code of a testlib checker or interactor to a verdict
+// and returns it with the program's comment
+func testlibVerdict(exitCode int, err error, comment string) (string, string) {
+	message := strings.TrimSpace(comment)
+	if len(message) > maxCheckerMessage {
+		message = message[:maxCheckerMessage] + "..."
+	}
+
+	switch exitCode {
+	case 0:
+		if err != nil {
+			break
+		}
+		return model.VerdictAccepted, message
+	case 1:
+		return model.VerdictWrongAnswer, message
+	case 2:
+		return model.VerdictPresentationError, message
+	case 7:
+		return model.VerdictPartial, message
+	}
+
+	if err != nil && message == "" {
+		message = err.Error()
+	}
+	return model.VerdictJudgeFailure, "judge program failed: " + message
+}
+
+// interactorJob builds the job that runs the problem interactor for one test; it
+// reads the test from input.txt and may leave notes in output.txt
+func interactorJob(interactor *model.Interactor, tc model.TestCase) (*executor.Job, error) {
+	language, version := parseLanguage(interactor.Language)
+	config, ok := executor.GetLanguageConfig(language)
+	if !ok {
+		return nil, fmt.Errorf("unsupported interactor language: %s", interactor.Language)
+	}
+	return &executor.Job{
+		Language: language,
+		Version:  version,
+		Files: []executor.File{
+			{Name: config.SourceFile, Content: interactor.Source},
+			{Name: checkerInput, Content: tc.Input},
+		},
+		Entrypoint: config.SourceFile,
+		Args:       []string{checkerInput, checkerOutput},
+	}, nil
+}
+
+// interactiveVerdict judges a run against an interactor. A program that failed to
+// build or ran out of time or memory gets that verdict whatever the interactor said,
+// since it saw the input cut off; otherwise the interactor's verdict wins over the
+// program's own failure.
+func interactiveVerdict(result executor.Result) (string, string) {
+	status := determineStatus(result, model.Comparator{}, "", "")
+	switch status {
+	case model.VerdictCompilationError, model.VerdictTimeLimit, model.VerdictMemoryLimit:
+		return status, ""
+	}
+	if result.Interaction == nil {
+		return status, ""
+	}
+	verdict, message := testlibVerdict(result.Interaction.ExitCode, result.Interaction.Error, result.Interaction.Output)
+	if verdict == model.VerdictAccepted && status != model.VerdictAccepted {
+		return status, message
+	}
+	return verdict, message
+}
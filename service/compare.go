@@ -0,0 +1,172 @@
+package service
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+
+	"xcodeengine/model"
+)
+
+// defaultEpsilon is the float tolerance used when a comparator sets none
+const defaultEpsilon = 1e-6
+
+// outputVerdict compares a participant's output with the expected answer and returns
+// AC, WA or, when the comparator allows it, PE for output that differs only in whitespace
+func outputVerdict(cmp model.Comparator, expected, actual string) (string, error) {
+	match, err := compareOutput(cmp, expected, actual)
+	if err != nil {
+		return "", err
+	}
+	if match {
+		return model.VerdictAccepted, nil
+	}
+	if cmp.PresentationError && equalTokens(strings.Fields(expected), strings.Fields(actual), func(a, b string) bool { return a == b }) {
+		return model.VerdictPresentationError, nil
+	}
+	return model.VerdictWrongAnswer, nil
+}
+
+// compareOutput reports whether a participant's output matches the expected answer
+// under the problem's comparator
+func compareOutput(cmp model.Comparator, expected, actual string) (bool, error) {
+	switch cmp.Mode {
+	case "", model.CompareExact:
+		return strings.TrimSpace(expected) == strings.TrimSpace(actual), nil
+	case model.CompareTokens:
+		return equalTokens(strings.Fields(expected), strings.Fields(actual), func(a, b string) bool { return a == b }), nil
+	case model.CompareCaseInsensitive:
+		return equalTokens(strings.Fields(expected), strings.Fields(actual), strings.EqualFold), nil
+	case model.CompareFloat:
+		return equalTokens(strings.Fields(expected), strings.Fields(actual), func(a, b string) bool {
+			return equalFloat(a, b, comparatorEpsilon(cmp))
+		}), nil
+	case model.CompareUnorderedLines:
+		return equalTokens(sortedLines(expected), sortedLines(actual), func(a, b string) bool { return a == b }), nil
+	case model.CompareValues:
+		want, err := decodeValue(expected)
+		if err != nil {
+			return false, fmt.Errorf("expected output is not JSON: %v", err)
+		}
+		got, err := decodeValue(actual)
+		if err != nil {
+			return false, nil
+		}
+		return equalValues(want, got, comparatorEpsilon(cmp)), nil
+	default:
+		return false, fmt.Errorf("unknown comparator: %s", cmp.Mode)
+	}
+}
+
+// comparatorEpsilon returns the float tolerance of a comparator
+func comparatorEpsilon(cmp model.Comparator) float64 {
+	if cmp.Epsilon <= 0 {
+		return defaultEpsilon
+	}
+	return cmp.Epsilon
+}
+
+func equalTokens(expected, actual []string, equal func(a, b string) bool) bool {
+	if len(expected) != len(actual) {
+		return false
+	}
+	for i := range expected {
+		if !equal(expected[i], actual[i]) {
+			return false
+		}
+	}
+	return true
+}
+
+// equalFloat compares numeric tokens within an absolute or relative epsilon,
+// and any other tokens exactly
+func equalFloat(expected, actual string, epsilon float64) bool {
+	want, err := strconv.ParseFloat(expected, 64)
+	if err != nil {
+		return expected == actual
+	}
+	got, err := strconv.ParseFloat(actual, 64)
+	if err != nil || math.IsNaN(got) || math.IsInf(got, 0) {
+		return false
+	}
+	diff := math.Abs(want - got)
+	return diff <= epsilon || diff <= epsilon*math.Abs(want)
+}
+
+// sortedLines returns the non-blank lines of an output, trimmed and sorted
+func sortedLines(output string) []string {
+	var lines []string
+	for _, line := range strings.Split(output, "\n") {
+		if line = strings.TrimSpace(line); line != "" {
+			lines = append(lines, line)
+		}
+	}
+	sort.Strings(lines)
+	return lines
+}
+
+// decodeValue reads one JSON value, keeping numbers as written
+func decodeValue(text string) (any, error) {
+	decoder := json.NewDecoder(strings.NewReader(text))
+	decoder.UseNumber()
+	var value any
+	if err := decoder.Decode(&value); err != nil {
+		return nil, err
+	}
+	if decoder.More() {
+		return nil, fmt.Errorf("more than one value")
+	}
+	return value, nil
+}
+
+// equalValues compares decoded JSON values: integers exactly, other numbers within
+// epsilon, and lists element by element. A null list equals an empty one, since some
+// languages return nil for an empty slice.
+func equalValues(expected, actual any, epsilon float64) bool {
+	switch want := expected.(type) {
+	case json.Number:
+		got, ok := actual.(json.Number)
+		if !ok {
+			return false
+		}
+		if a, err := want.Int64(); err == nil {
+			if b, err := got.Int64(); err == nil {
+				return a == b
+			}
+		}
+		return equalFloat(want.String(), got.String(), epsilon)
+	case []any:
+		if actual == nil {
+			return len(want) == 0
+		}
+		got, ok := actual.([]any)
+		if !ok || len(got) != len(want) {
+			return false
+		}
+		for i := range want {
+			if !equalValues(want[i], got[i], epsilon) {
+				return false
+			}
+		}
+		return true
+	case map[string]any:
+		got, ok := actual.(map[string]any)
+		if !ok || len(got) != len(want) {
+			return false
+		}
+		for key, value := range want {
+			if other, ok := got[key]; !ok || !equalValues(value, other, epsilon) {
+				return false
+			}
+		}
+		return true
+	case nil:
+		got, ok := actual.([]any)
+		return actual == nil || (ok && len(got) == 0)
+	default:
+		return expected == actual
+	}
+}
@@ -0,0 +1,125 @@
+package service
+
+import (
+	"fmt"
+	"strings"
+
+	"xcodeengine/model"
+)
+
+// Bounds on the diff attached to a failed test
+const (
+	diffContext   = 3   // unchanged lines shown before the first mismatch
+	diffWindow    = 100 // lines of each output compared after the first mismatch
+	maxDiffLines  = 50  // lines of unified diff kept
+	maxDiffLength = 200 // bytes kept per diff line
+)
+
+// diffOutputs locates the first mismatch between two outputs and renders a bounded
+// unified diff around it; it returns nil when the outputs are identical
+func diffOutputs(expected, actual string) *model.OutputDiff {
+	if expected == actual {
+		return nil
+	}
+	want := strings.Split(expected, "\n")
+	got := strings.Split(actual, "\n")
+
+	line := 0
+	for line < len(want) && line < len(got) && want[line] == got[line] {
+		line++
+	}
+
+	diff := &model.OutputDiff{Line: line + 1, Column: 1}
+	if line < len(want) {
+		diff.Expected = clipLine(want[line])
+	}
+	if line < len(got) {
+		diff.Actual = clipLine(got[line])
+	}
+	if line < len(want) && line < len(got) {
+		a, b := want[line], got[line]
+		col := 0
+		for col < len(a) && col < len(b) && a[col] == b[col] {
+			col++
+		}
+		diff.Column = col + 1
+	}
+
+	start := max(line-diffContext, 0)
+	diff.Unified = unifiedDiff(window(want, start), window(got, start), start)
+	return diff
+}
+
+// window returns the lines compared for the diff, starting at start
+func window(lines []string, start int) []string {
+	if start >= len(lines) {
+		return nil
+	}
+	return lines[start:min(len(lines), start+diffContext+diffWindow)]
+}
+
+// unifiedDiff renders one hunk of a line diff between two windows that both
+// begin at line offset of their outputs
+func unifiedDiff(want, got []string, offset int) string {
+	// Longest common subsequence, computed from the end so the script can be read forwards
+	lcs := make([][]int, len(want)+1)
+	for i := range lcs {
+		lcs[i] = make([]int, len(got)+1)
+	}
+	for i := len(want) - 1; i >= 0; i-- {
+		for j := len(got) - 1; j >= 0; j-- {
+			if want[i] == got[j] {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			} else {
+				lcs[i][j] = max(lcs[i+1][j], lcs[i][j+1])
+			}
+		}
+	}
+
+	var lines []string
+	i, j := 0, 0
+	for i < len(want) || j < len(got) {
+		switch {
+		case i < len(want) && j < len(got) && want[i] == got[j]:
+			lines = append(lines, " "+clipLine(want[i]))
+			i++
+			j++
+		case i < len(want) && (j == len(got) || lcs[i+1][j] >= lcs[i][j+1]):
+			lines = append(lines, "-"+clipLine(want[i]))
+			i++
+		default:
+			lines = append(lines, "+"+clipLine(got[j]))
+			j++
+		}
+	}
+
+	// Drop the unchanged tail; only the lines up to the last change are interesting
+	last := len(lines)
+	for last > 0 && strings.HasPrefix(lines[last-1], " ") {
+		last--
+	}
+	lines = lines[:min(last+diffContext, len(lines), maxDiffLines)]
+
+	removed, added := 0, 0
+	for _, line := range lines {
+		if line[0] != '+' {
+			removed++
+		}
+		if line[0] != '-' {
+			added++
+		}
+	}
+	if last > maxDiffLines {
+		lines = append(lines, "...")
+	}
+
+	header := fmt.Sprintf("--- expected\n+++ output\n@@ -%d,%d +%d,%d @@\n", offset+1, removed, offset+1, added)
+	return header + strings.Join(lines, "\n")
+}
+
+func clipLine(line string) string {
+	if len(line) > maxDiffLength {
+		return line[:maxDiffLength] + "..."
+	}
+	return line
+}
@@ -0,0 +1,149 @@
+package service
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"xcodeengine/executor"
+	"xcodeengine/model"
+	"xcodeengine/problems"
+)
+
+// ErrNoTestScript is returned when materialising tests for a problem without a test script
+var ErrNoTestScript = errors.New("problem has no test script")
+
+// GenerateTests runs a problem's test script in the sandbox and saves the inputs as
+// its generated tests, after the written ones, in a new problem version. Inputs are
+// cached by a hash of the generator, args and seed, so unchanged lines do not run
+// again. When the problem has a reference solution, it then produces the expected
+// outputs; otherwise a test keeps the output of the previous test with the same input.
+func (s *CompilerService) GenerateTests(problemID string) (*model.TestGenerationResponse, error) {
+	store := problems.Active()
+	problem, ok := store.Get(problemID)
+	if !ok {
+		return nil, ErrProblemNotFound
+	}
+	if len(problem.TestScript) == 0 {
+		return nil, ErrNoTestScript
+	}
+
+	generators := make(map[string]model.Generator, len(problem.Generators))
+	for _, g := range problem.Generators {
+		generators[g.Name] = g
+	}
+
+	inputs := make([]string, len(problem.TestScript))
+	report := make([]model.GeneratedInput, len(problem.TestScript))
+	var (
+		jobs    []executor.Job
+		pending []int // script lines the jobs run, in order
+	)
+	for i, line := range problem.TestScript {
+		generator := generators[line.Generator]
+		hash := scriptHash(generator, line)
+		report[i] = model.GeneratedInput{Name: scriptTestName(line), Hash: hash}
+		if input, ok := store.CachedInput(hash); ok {
+			inputs[i] = input
+			report[i].Cached = true
+			continue
+		}
+		job, err := generatorJob(generator, line)
+		if err != nil {
+			return nil, err
+		}
+		jobs = append(jobs, job)
+		pending = append(pending, i)
+	}
+
+	for j, result := range s.runJobs(jobs) {
+		i := pending[j]
+		if result.Error != nil || !result.Success {
+			return nil, fmt.Errorf("test script line %d (%s) failed: %v: %s", i+1, report[i].Name, result.Error, strings.TrimSpace(result.Output))
+		}
+		// The input is what the generator prints on stdout; anything on stderr is
+		// likely debugging left in, which the test must not depend on
+		if stderr := strings.TrimSpace(result.Stderr); stderr != "" {
+			return nil, fmt.Errorf("test script line %d (%s) wrote to stderr: %s", i+1, report[i].Name, stderr)
+		}
+		inputs[i] = result.Stdout
+		if err := store.CacheInput(report[i].Hash, result.Stdout); err != nil {
+			return nil, fmt.Errorf("failed to cache generated input: %v", err)
+		}
+	}
+
+	// Expected outputs carry over by input until the reference solution runs
+	previous := make(map[string]string, len(problem.TestCases))
+	updated := *problem
+	updated.TestCases = nil
+	for _, tc := range problem.TestCases {
+		previous[tc.Input] = tc.ExpectedOutput
+		if !tc.Generated {
+			updated.TestCases = append(updated.TestCases, tc)
+		}
+	}
+	for i, line := range problem.TestScript {
+		report[i].Size = len(inputs[i])
+		updated.TestCases = append(updated.TestCases, model.TestCase{
+			Name:           report[i].Name,
+			Input:          inputs[i],
+			ExpectedOutput: previous[inputs[i]],
+			Hidden:         !line.Sample,
+			Generated:      true,
+		})
+	}
+
+	saved, err := store.Update(updated)
+	if err != nil {
+		return nil, err
+	}
+	response := &model.TestGenerationResponse{ProblemID: saved.ID, ProblemVersion: saved.Version, Tests: report}
+
+	if saved.Solution != nil && saved.Interactor == nil {
+		outputs, err := s.GenerateExpectedOutputs(saved.ID)
+		if err != nil {
+			return nil, err
+		}
+		response.Outputs = outputs
+		response.ProblemVersion = outputs.ProblemVersion
+	}
+	return response, nil
+}
+
+// generatorJob builds the job that runs one line of a test script
+func generatorJob(generator model.Generator, line model.GeneratedTest) (executor.Job, error) {
+	language, version := parseLanguage(generator.Language)
+	config, ok := executor.GetLanguageConfig(language)
+	if !ok {
+		return executor.Job{}, fmt.Errorf("unsupported generator language: %s", generator.Language)
+	}
+	return executor.Job{
+		Language:   language,
+		Version:    version,
+		Files:      []executor.File{{Name: config.SourceFile, Content: generator.Source}},
+		Entrypoint: config.SourceFile,
+		Args:       append(append([]string(nil), line.Args...), strconv.FormatInt(line.Seed, 10)),
+	}, nil
+}
+
+// scriptHash identifies the input a script line produces: the same generator source,
+// args and seed always give the same input
+func scriptHash(generator model.Generator, line model.GeneratedTest) string {
+	h := sha256.New()
+	for _, part := range append([]string{generator.Language, generator.Source, strconv.FormatInt(line.Seed, 10)}, line.Args...) {
+		h.Write([]byte(part))
+		h.Write([]byte{0})
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// scriptTestName names a generated test after its script line unless the line names it
+func scriptTestName(line model.GeneratedTest) string {
+	if line.Name != "" {
+		return line.Name
+	}
+	return strings.Join(append(append([]string{line.Generator}, line.Args...), strconv.FormatInt(line.Seed, 10)), " ")
+}
@@ -0,0 +1,417 @@
+package service
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"xcodeengine/executor"
+	"xcodeengine/model"
+	"xcodeengine/problems"
+)
+
+// goPackageClause matches the package clause of a Go source file
+var goPackageClause = regexp.MustCompile(`(?m)^\s*package\s+\w+`)
+
+// functionHarness wraps a participant's function in a program that reads a test's
+// arguments, a JSON array, from stdin, calls the function and prints the return value
+// as JSON after a marker line. It returns the program's files and entrypoint, and the
+// marker, which separates the value from anything the function printed itself.
+func functionHarness(fn *model.Function, language, code string) ([]executor.File, string, string, error) {
+	config, ok := executor.GetLanguageConfig(language)
+	if !ok {
+		return nil, "", "", fmt.Errorf("unsupported language: %s", language)
+	}
+	marker, err := harnessMarker()
+	if err != nil {
+		return nil, "", "", err
+	}
+
+	args := make([]string, len(fn.Params))
+	for i := range fn.Params {
+		args[i] = "arg" + strconv.Itoa(i)
+	}
+	count := strconv.Itoa(len(fn.Params))
+
+	var files []executor.File
+	switch language {
+	case "python":
+		files = []executor.File{{Name: config.SourceFile, Content: code + "\n" + strings.NewReplacer(
+			"{{CALL}}", fn.Name+"(*args)",
+			"{{MARKER}}", marker,
+		).Replace(pythonHarness)}}
+	case "js":
+		files = []executor.File{{Name: config.SourceFile, Content: code + "\n" + strings.NewReplacer(
+			"{{CALL}}", fn.Name+"(...args)",
+			"{{MARKER}}", marker,
+		).Replace(jsHarness)}}
+	case "cpp":
+		var decode strings.Builder
+		for i, param := range fn.Params {
+			fmt.Fprintf(&decode, "    auto arg%d = args.at(%d).get<%s>();\n", i, i, cppType(param.Type))
+		}
+		files = []executor.File{{Name: config.SourceFile, Content: strings.NewReplacer(
+			"{{CODE}}", strings.TrimRight(code, "\n"),
+			"{{COUNT}}", count,
+			"{{DECODE}}", decode.String(),
+			"{{CALL}}", fn.Name+"("+strings.Join(args, ", ")+")",
+			"{{MARKER}}", marker,
+		).Replace(cppHarness)}}
+	case "go":
+		if !goPackageClause.MatchString(code) {
+			code = "package main\n\n" + code
+		}
+		var decode strings.Builder
+		for i, param := range fn.Params {
+			fmt.Fprintf(&decode, "\tvar arg%d %s\n\tif err := json.Unmarshal(args[%d], &arg%d); err != nil {\n\t\tfail(err)\n\t}\n", i, goType(param.Type), i, i)
+		}
+		files = []executor.File{
+			{Name: "solution.go", Content: code},
+			{Name: config.SourceFile, Content: strings.NewReplacer(
+				"{{COUNT}}", count,
+				"{{DECODE}}", decode.String(),
+				"{{CALL}}", fn.Name+"("+strings.Join(args, ", ")+")",
+				"{{MARKER}}", marker,
+			).Replace(goHarness)},
+		}
+	case "java":
+		var decode strings.Builder
+		for i, param := range fn.Params {
+			fmt.Fprintf(&decode, "        %s arg%d = (%s) convert(args.get(%d), %q);\n", javaType(param.Type, false), i, javaType(param.Type, true), i, param.Type)
+		}
+		files = []executor.File{
+			{Name: "Solution.java", Content: code},
+			{Name: config.SourceFile, Content: strings.NewReplacer(
+				"{{COUNT}}", count,
+				"{{DECODE}}", decode.String(),
+				"{{CALL}}", "new Solution()."+fn.Name+"("+strings.Join(args, ", ")+")",
+				"{{MARKER}}", marker,
+			).Replace(javaHarness)},
+		}
+	default:
+		return nil, "", "", fmt.Errorf("function problems do not support %s", language)
+	}
+	return files, config.SourceFile, marker, nil
+}
+
+// harnessMarker returns a line no function prints by chance
+func harnessMarker() (string, error) {
+	b := make([]byte, 12)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return "=== return value " + hex.EncodeToString(b) + " ===", nil
+}
+
+// functionValue returns what a harness printed after its marker: the function's
+// return value. A run that never got that far returns nothing.
+func functionValue(stdout, marker string) string {
+	i := strings.LastIndex(stdout, marker)
+	if i < 0 {
+		return ""
+	}
+	return strings.TrimSpace(stdout[i+len(marker):])
+}
+
+// goType spells a function-signature type in Go
+func goType(typ string) string {
+	base, depth, _ := problems.ParseType(typ)
+	names := map[string]string{
+		model.TypeInt:    "int",
+		model.TypeLong:   "int64",
+		model.TypeDouble: "float64",
+		model.TypeBool:   "bool",
+		model.TypeString: "string",
+	}
+	return strings.Repeat("[]", depth) + names[base]
+}
+
+// cppType spells a function-signature type in C++
+func cppType(typ string) string {
+	base, depth, _ := problems.ParseType(typ)
+	names := map[string]string{
+		model.TypeInt:    "int",
+		model.TypeLong:   "long long",
+		model.TypeDouble: "double",
+		model.TypeBool:   "bool",
+		model.TypeString: "string",
+	}
+	return strings.Repeat("vector<", depth) + names[base] + strings.Repeat(">", depth)
+}
+
+// javaType spells a function-signature type in Java; boxed picks the wrapper class of
+// a primitive, for casts from Object
+func javaType(typ string, boxed bool) string {
+	base, depth, _ := problems.ParseType(typ)
+	names := map[string]string{
+		model.TypeInt:    "int",
+		model.TypeLong:   "long",
+		model.TypeDouble: "double",
+		model.TypeBool:   "boolean",
+		model.TypeString: "String",
+	}
+	if boxed && depth == 0 {
+		names = map[string]string{
+			model.TypeInt:    "Integer",
+			model.TypeLong:   "Long",
+			model.TypeDouble: "Double",
+			model.TypeBool:   "Boolean",
+			model.TypeString: "String",
+		}
+	}
+	return names[base] + strings.Repeat("[]", depth)
+}
+
+// pythonHarness is appended to a Python submission
+const pythonHarness = `
+
+def _harness():
+    import json, sys
+    args = json.loads(sys.stdin.read())
+    result = {{CALL}}
+    print("\n{{MARKER}}")
+    print(json.dumps(result))
+
+
+_harness()
+`
+
+// jsHarness is appended to a JavaScript submission
+const jsHarness = `
+;(() => {
+  const args = JSON.parse(require("fs").readFileSync(0, "utf8"));
+  const result = {{CALL}};
+  console.log("\n{{MARKER}}");
+  console.log(JSON.stringify(result));
+})();
+`
+
+// cppHarness surrounds a C++ submission; #line keeps compiler messages on the
+// submission's own line numbers
+const cppHarness = `#include <bits/stdc++.h>
+#include <nlohmann/json.hpp>
+using namespace std;
+#line 1 "solution.cpp"
+{{CODE}}
+#line 1 "harness.cpp"
+int main() {
+    nlohmann::json args = nlohmann::json::parse(cin);
+    if (args.size() != {{COUNT}}) {
+        cerr << "harness: want {{COUNT}} arguments" << endl;
+        return 1;
+    }
+{{DECODE}}    auto result = {{CALL}};
+    // The function may have printed with C stdio, unsynchronised with cout
+    fflush(stdout);
+    cout << "\n{{MARKER}}\n" << nlohmann::json(result).dump() << endl;
+    return 0;
+}
+`
+
+// goHarness is the main file built next to a Go submission
+const goHarness = `package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+)
+
+func main() {
+	fail := func(err error) {
+		fmt.Fprintln(os.Stderr, "harness:", err)
+		os.Exit(1)
+	}
+	input, err := io.ReadAll(os.Stdin)
+	if err != nil {
+		fail(err)
+	}
+	var args []json.RawMessage
+	if err := json.Unmarshal(input, &args); err != nil {
+		fail(err)
+	}
+	if len(args) != {{COUNT}} {
+		fail(fmt.Errorf("want {{COUNT}} arguments, got %d", len(args)))
+	}
+{{DECODE}}	output, err := json.Marshal({{CALL}})
+	if err != nil {
+		fail(err)
+	}
+	fmt.Println("\n{{MARKER}}")
+	fmt.Println(string(output))
+}
+`
+
+// javaHarness is the main class built next to a Java submission's Solution class. It
+// carries a small JSON reader and writer, since the JDK has none.
+const javaHarness = `import java.lang.reflect.Array;
+import java.math.BigDecimal;
+import java.nio.charset.StandardCharsets;
+import java.util.ArrayList;
+import java.util.Collection;
+import java.util.List;
+
+public class Main {
+    public static void main(String[] argv) throws Exception {
+        String input = new String(System.in.readAllBytes(), StandardCharsets.UTF_8);
+        List<?> args = (List<?>) new Main(input).parse();
+        if (args.size() != {{COUNT}}) {
+            throw new IllegalArgumentException("harness: want {{COUNT}} arguments");
+        }
+{{DECODE}}        Object result = {{CALL}};
+        StringBuilder out = new StringBuilder();
+        write(out, result);
+        System.out.println("\n{{MARKER}}");
+        System.out.println(out);
+    }
+
+    private final String text;
+    private int pos;
+
+    private Main(String text) {
+        this.text = text;
+    }
+
+    private void skip() {
+        while (pos < text.length() && Character.isWhitespace(text.charAt(pos))) {
+            pos++;
+        }
+    }
+
+    private Object parse() {
+        skip();
+        char c = text.charAt(pos);
+        if (c == '[') {
+            pos++;
+            List<Object> list = new ArrayList<>();
+            skip();
+            if (text.charAt(pos) == ']') {
+                pos++;
+                return list;
+            }
+            while (true) {
+                list.add(parse());
+                skip();
+                if (text.charAt(pos++) == ']') {
+                    return list;
+                }
+            }
+        }
+        if (c == '"') {
+            StringBuilder b = new StringBuilder();
+            pos++;
+            while (text.charAt(pos) != '"') {
+                char ch = text.charAt(pos++);
+                if (ch != '\\') {
+                    b.append(ch);
+                    continue;
+                }
+                char e = text.charAt(pos++);
+                switch (e) {
+                    case 'n': b.append('\n'); break;
+                    case 't': b.append('\t'); break;
+                    case 'r': b.append('\r'); break;
+                    case 'b': b.append('\b'); break;
+                    case 'f': b.append('\f'); break;
+                    case 'u': b.append((char) Integer.parseInt(text.substring(pos, pos + 4), 16)); pos += 4; break;
+                    default: b.append(e);
+                }
+            }
+            pos++;
+            return b.toString();
+        }
+        if (text.startsWith("true", pos)) {
+            pos += 4;
+            return Boolean.TRUE;
+        }
+        if (text.startsWith("false", pos)) {
+            pos += 5;
+            return Boolean.FALSE;
+        }
+        if (text.startsWith("null", pos)) {
+            pos += 4;
+            return null;
+        }
+        int start = pos;
+        while (pos < text.length() && "+-0123456789.eE".indexOf(text.charAt(pos)) >= 0) {
+            pos++;
+        }
+        return new BigDecimal(text.substring(start, pos));
+    }
+
+    private static Object convert(Object value, String type) {
+        if (type.endsWith("[]")) {
+            String element = type.substring(0, type.length() - 2);
+            List<?> list = (List<?>) value;
+            Object array = Array.newInstance(javaClass(element), list.size());
+            for (int i = 0; i < list.size(); i++) {
+                Array.set(array, i, convert(list.get(i), element));
+            }
+            return array;
+        }
+        switch (type) {
+            case "int": return ((Number) value).intValue();
+            case "long": return ((Number) value).longValue();
+            case "double": return ((Number) value).doubleValue();
+            default: return value;
+        }
+    }
+
+    private static Class<?> javaClass(String type) {
+        if (type.endsWith("[]")) {
+            return Array.newInstance(javaClass(type.substring(0, type.length() - 2)), 0).getClass();
+        }
+        switch (type) {
+            case "int": return int.class;
+            case "long": return long.class;
+            case "double": return double.class;
+            case "bool": return boolean.class;
+            default: return String.class;
+        }
+    }
+
+    private static void write(StringBuilder out, Object value) {
+        if (value == null) {
+            out.append("null");
+        } else if (value.getClass().isArray()) {
+            out.append('[');
+            for (int i = 0; i < Array.getLength(value); i++) {
+                if (i > 0) {
+                    out.append(',');
+                }
+                write(out, Array.get(value, i));
+            }
+            out.append(']');
+        } else if (value instanceof Collection) {
+            out.append('[');
+            boolean first = true;
+            for (Object element : (Collection<?>) value) {
+                if (!first) {
+                    out.append(',');
+                }
+                first = false;
+                write(out, element);
+            }
+            out.append(']');
+        } else if (value instanceof String || value instanceof Character) {
+            out.append('"');
+            for (char c : value.toString().toCharArray()) {
+                if (c == '"' || c == '\\') {
+                    out.append('\\').append(c);
+                } else if (c < 0x20) {
+                    out.append(String.format("\\u%04x", (int) c));
+                } else {
+                    out.append(c);
+                }
+            }
+            out.append('"');
+        } else {
+            out.append(value);
+        }
+    }
+}
+`
@@ -0,0 +1,114 @@
+package service
+
+import (
+	"fmt"
+	"log"
+	"time"
+	"unicode"
+
+	"xcodeengine/model"
+	"xcodeengine/submissions"
+)
+
+// maxSubmitterLength caps the submitter name a submission may carry
+const maxSubmitterLength = 128
+
+// JudgeSubmission judges a submission like JudgeProblemStream and records it, with
+// its outcome, in the submission store when there is one. The response names the
+// record once it is kept.
+func (s *CompilerService) JudgeSubmission(req model.ProblemSubmissionRequest, progress JudgeProgress) (*model.JudgeResponse, error) {
+	// Submissions to a problem that takes none are not worth a record
+	if _, err := openProblem(req.ProblemID); err != nil {
+		return nil, err
+	}
+	submission, err := newSubmission(req)
+	if err != nil {
+		return nil, err
+	}
+	started := time.Now().UTC()
+	submission.Started = &started
+	submission.Status = model.SubmissionRunning
+
+	resp, err := s.JudgeProblemStream(req.Code, req.Language, req.ProblemID, req.JudgeMode, req.Flags, req.ProjectFiles, progress)
+	finishSubmission(submission, resp, err)
+	if err != nil {
+		recordSubmission(submission)
+		return nil, err
+	}
+	resp.SubmissionID = submission.ID
+	if !recordSubmission(submission) {
+		resp.SubmissionID = ""
+	}
+	return resp, nil
+}
+
+// newSubmission starts the record of a submission in the queued state
+func newSubmission(req model.ProblemSubmissionRequest) (*model.Submission, error) {
+	if err := validateSubmitter(req.Submitter); err != nil {
+		return nil, err
+	}
+	id, err := newSubmissionID()
+	if err != nil {
+		return nil, err
+	}
+	return &model.Submission{
+		ID:           id,
+		Submitter:    req.Submitter,
+		ProblemID:    req.ProblemID,
+		Language:     req.Language,
+		Code:         req.Code,
+		Flags:        req.Flags,
+		ProjectFiles: req.ProjectFiles,
+		Status:       model.SubmissionQueued,
+		Created:      time.Now().UTC(),
+	}, nil
+}
+
+// finishSubmission records the outcome of judging a submission
+func finishSubmission(submission *model.Submission, resp *model.JudgeResponse, err error) {
+	finished := time.Now().UTC()
+	submission.Finished = &finished
+	submission.Tests = nil
+	if err != nil {
+		submission.Status = model.SubmissionFailed
+		submission.Error = err.Error()
+		return
+	}
+	submission.Status = model.SubmissionFinished
+	submission.ProblemVersion = resp.ProblemVersion
+	submission.Completed = 0
+	for _, result := range resp.Results {
+		if result.Status != model.VerdictSkipped {
+			submission.Completed++
+		}
+	}
+	submission.Result = resp
+}
+
+// recordSubmission keeps a finished submission in the submission store, if any, and
+// reports whether it was kept. A failure to record is logged; the submission has been
+// judged either way.
+func recordSubmission(submission *model.Submission) bool {
+	store := submissions.Active()
+	if store == nil {
+		return false
+	}
+	if err := store.Put(submission); err != nil {
+		log.Printf("Failed to record submission %s: %v", submission.ID, err)
+		return false
+	}
+	return true
+}
+
+// validateSubmitter checks that a submitter name can index the submission store
+func validateSubmitter(submitter string) error {
+	if len(submitter) > maxSubmitterLength {
+		return fmt.Errorf("submitter is longer than %d bytes", maxSubmitterLength)
+	}
+	for _, r := range submitter {
+		if unicode.IsControl(r) {
+			return fmt.Errorf("submitter contains control characters")
+		}
+	}
+	return nil
+}
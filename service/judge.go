@@ -0,0 +1,363 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"xcodeengine/executor"
+	"xcodeengine/model"
+	"xcodeengine/problems"
+)
+
+// JudgeProgress receives judge events while a submission runs; it may be called
+// from several goroutines and must be safe for concurrent use
+type JudgeProgress func(event model.JudgeEvent)
+
+// JudgeProblem runs a submission against a problem's tests. mode overrides the
+// problem's judging mode when set.
+func (s *CompilerService) JudgeProblem(code, language, problemID, mode string, flags []string, project model.ProjectFiles) (*model.JudgeResponse, error) {
+	return s.JudgeProblemStream(code, language, problemID, mode, flags, project, nil)
+}
+
+// JudgeProblemStream is JudgeProblem with test progress and output pushed to progress as the tests run
+func (s *CompilerService) JudgeProblemStream(code, language, problemID, mode string, flags []string, project model.ProjectFiles, progress JudgeProgress) (*model.JudgeResponse, error) {
+	if progress == nil {
+		progress = func(model.JudgeEvent) {}
+	}
+
+	problem, err := openProblem(problemID)
+	if err != nil {
+		return nil, err
+	}
+	if mode == "" {
+		mode = problem.JudgeMode
+	}
+	switch mode {
+	case "", model.JudgeModeFull:
+		mode = model.JudgeModeFull
+	case model.JudgeModeICPC:
+	default:
+		return nil, fmt.Errorf("unknown judge mode: %s", mode)
+	}
+
+	language, version := parseLanguage(language)
+	files, err := prepareSource(code, language, project, 1000000)
+	if err != nil {
+		return nil, err
+	}
+	var marker string
+	if problem.Function != nil {
+		if files != nil {
+			return nil, fmt.Errorf("problem %s takes a single function, not a project", problem.ID)
+		}
+		if files, project.Entrypoint, marker, err = functionHarness(problem.Function, language, code); err != nil {
+			return nil, err
+		}
+	}
+	// Problems may set default flags per language; explicit request flags win
+	if len(flags) == 0 {
+		flags = problem.DefaultFlags[language]
+	}
+	version, _, _, err = executor.ResolveFlags(language, version, flags)
+	if err != nil {
+		return nil, err
+	}
+	resolved, err := executor.ResolveVersion(language, version)
+	if err != nil {
+		return nil, err
+	}
+
+	run := &judgeRun{
+		service:     s,
+		problem:     problem,
+		language:    language,
+		version:     resolved.Name,
+		flags:       flags,
+		code:        code,
+		files:       files,
+		entrypoint:  project.Entrypoint,
+		marker:      marker,
+		progress:    progress,
+		stopOnFail:  mode == model.JudgeModeICPC,
+		parallelism: s.WorkerPool.JudgeParallelism(language, resolved.Name),
+		failed:      -1,
+		results:     make([]*model.TestCaseResult, len(problem.TestCases)),
+	}
+	run.timeLimit, run.memoryLimit = problemLimits(problem, language, resolved.Name)
+
+	response := &model.JudgeResponse{
+		ProblemID:      problem.ID,
+		Verdict:        model.VerdictAccepted,
+		JudgeMode:      mode,
+		Version:        language + "@" + resolved.Name,
+		ProblemVersion: problem.Version,
+	}
+
+	if len(problem.Subtasks) > 0 {
+		response.Subtasks, response.Score, response.MaxScore = run.judgeSubtasks()
+	}
+
+	// Tests outside every subtask still run; tests of skipped subtasks only do if another subtask needs them
+	scored := subtaskTests(problem.Subtasks)
+	var rest []int
+	for i := range problem.TestCases {
+		if run.results[i] == nil && !scored[i] {
+			rest = append(rest, i)
+		}
+	}
+	run.runTests(rest)
+
+	response.Summary = "Accepted"
+	for i, tc := range problem.TestCases {
+		result := run.results[i]
+		if result == nil {
+			result = &model.TestCaseResult{Name: tc.Name, Status: model.VerdictSkipped}
+		}
+		response.Results = append(response.Results, *result)
+	}
+	if run.failed >= 0 {
+		response.Verdict = run.results[run.failed].Status
+		response.Summary = fmt.Sprintf("%s on test %d", response.Verdict, run.failed+1)
+	}
+
+	return response, nil
+}
+
+// openProblem returns a problem that takes submissions
+func openProblem(problemID string) (*model.Problem, error) {
+	problem, ok := problems.GetProblem(problemID)
+	if !ok {
+		return nil, ErrProblemNotFound
+	}
+	if problem.Archived {
+		return nil, ErrProblemArchived
+	}
+	if problem.Draft {
+		return nil, ErrProblemDraft
+	}
+	if !problems.Materialised(problem) {
+		return nil, ErrTestsNotReady
+	}
+	return problem, nil
+}
+
+// judgeRun holds what the tests of one submission share, and their results by test index
+type judgeRun struct {
+	service     *CompilerService
+	problem     *model.Problem
+	language    string
+	version     string
+	flags       []string
+	code        string
+	files       []executor.File
+	entrypoint  string
+	marker      string // function problems: the line the harness prints before the return value
+	timeLimit   time.Duration
+	memoryLimit int64
+	progress    JudgeProgress
+	stopOnFail  bool // ICPC mode: once a test fails, the tests after it are skipped
+	parallelism int  // tests that may run at once, each in its own container
+
+	mu      sync.Mutex
+	failed  int // index of the lowest test that failed, or -1
+	results []*model.TestCaseResult
+}
+
+// runTests runs the tests at the given indices, up to parallelism at a time. Each
+// test holds its own container, so their time measurements do not interfere.
+func (r *judgeRun) runTests(indices []int) {
+	sem := make(chan struct{}, r.parallelism)
+	var wg sync.WaitGroup
+	for _, i := range indices {
+		sem <- struct{}{}
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			defer func() { <-sem }()
+			r.test(i)
+		}(i)
+	}
+	wg.Wait()
+}
+
+// test runs the test at index i once and stores its result in r.results
+func (r *judgeRun) test(i int) {
+	tc := r.problem.TestCases[i]
+	r.mu.Lock()
+	if r.results[i] != nil {
+		r.mu.Unlock()
+		return
+	}
+	if r.stopOnFail && r.failed >= 0 && r.failed < i {
+		r.results[i] = &model.TestCaseResult{Name: tc.Name, Status: model.VerdictSkipped}
+		r.mu.Unlock()
+		return
+	}
+	r.mu.Unlock()
+
+	test, total := i+1, len(r.problem.TestCases)
+	r.progress(model.JudgeEvent{Type: "test_started", Test: test, Total: total, Name: tc.Name})
+
+	var interactor *executor.Job
+	if r.problem.Interactor != nil {
+		job, err := interactorJob(r.problem.Interactor, tc)
+		if err != nil {
+			r.record(i, model.TestCaseResult{Name: tc.Name, Status: model.VerdictJudgeFailure, Error: err.Error()})
+			return
+		}
+		interactor = job
+	}
+
+	// Hidden tests must not leak their output while they run
+	var stream func(executor.OutputChunk)
+	if !tc.Hidden {
+		stream = func(chunk executor.OutputChunk) {
+			r.progress(model.JudgeEvent{Type: "output", Test: test, Total: total, Name: tc.Name, Stream: chunk.Stream, Data: chunk.Data})
+		}
+	}
+
+	execResult := r.service.WorkerPool.ExecuteJobWait(executor.Job{
+		Language:    r.language,
+		Version:     r.version,
+		Flags:       r.flags,
+		Code:        r.code,
+		Files:       r.files,
+		Entrypoint:  r.entrypoint,
+		Input:       tc.Input,
+		Interactor:  interactor,
+		TimeLimit:   r.timeLimit,
+		MemoryLimit: r.memoryLimit,
+		Stream:      stream,
+	})
+
+	output := execResult.Output
+	if r.marker != "" {
+		// Only the return value is judged, not what the function printed
+		output = functionValue(execResult.Stdout, r.marker)
+	}
+	caseResult := model.TestCaseResult{
+		Name:          tc.Name,
+		Input:         tc.Input,
+		Expected:      strings.TrimSpace(tc.ExpectedOutput),
+		Output:        strings.TrimSpace(output),
+		ExecutionTime: execResult.ExecutionTime,
+		Memory:        execResult.Memory,
+	}
+
+	status := determineStatus(execResult, problemComparator(r.problem), tc.ExpectedOutput, output)
+	if interactor != nil {
+		status, caseResult.Message = interactiveVerdict(execResult)
+		if execResult.Interaction != nil {
+			caseResult.Transcript = execResult.Interaction.Transcript
+		}
+	} else if r.problem.Checker != nil && execResult.Error == nil && execResult.Success {
+		checked := execResult.Stdout
+		if r.marker != "" {
+			checked = output
+		}
+		status, caseResult.Message = r.service.runChecker(r.problem.Checker, tc, checked)
+	} else if status == model.VerdictWrongAnswer || status == model.VerdictPresentationError {
+		caseResult.Diff = diffOutputs(caseResult.Expected, caseResult.Output)
+	}
+	caseResult.Status = status
+	caseResult.Points = testPoints(status, caseResult.Message)
+
+	if execResult.Error != nil {
+		caseResult.Error = execResult.Error.Error()
+	}
+	if tc.Hidden {
+		caseResult = caseResult.Redacted()
+	}
+
+	r.record(i, caseResult)
+}
+
+// record stores the result of the test at index i and reports it as finished. In ICPC
+// mode, tests after the first failure may have run alongside it; they are reported as
+// skipped, and those already reported get a second test_finished that skips them.
+func (r *judgeRun) record(i int, result model.TestCaseResult) {
+	total := len(r.results)
+	var corrections []model.JudgeEvent
+	r.mu.Lock()
+	if r.stopOnFail && r.failed >= 0 && r.failed < i {
+		result = model.TestCaseResult{Name: result.Name, Status: model.VerdictSkipped}
+	}
+	r.results[i] = &result
+	if result.Status != model.VerdictAccepted && (r.failed < 0 || i < r.failed) {
+		r.failed = i
+		for j := i + 1; r.stopOnFail && j < total; j++ {
+			if r.results[j] != nil && r.results[j].Status != model.VerdictSkipped {
+				skipped := model.TestCaseResult{Name: r.results[j].Name, Status: model.VerdictSkipped}
+				r.results[j] = &skipped
+				corrections = append(corrections, model.JudgeEvent{Type: "test_finished", Test: j + 1, Total: total, Name: skipped.Name, Result: &skipped})
+			}
+		}
+	}
+	r.mu.Unlock()
+
+	r.progress(model.JudgeEvent{Type: "test_finished", Test: i + 1, Total: total, Name: result.Name, Result: &result})
+	for _, event := range corrections {
+		r.progress(event)
+	}
+}
+
+func determineStatus(result executor.Result, cmp model.Comparator, expected, actual string) string {
+	if result.Error != nil {
+		switch {
+		case errors.Is(result.Error, executor.ErrInteractorFailed):
+			return model.VerdictJudgeFailure
+		case errors.Is(result.Error, executor.ErrBuildFailed):
+			return model.VerdictCompilationError
+		case errors.Is(result.Error, executor.ErrTimeLimitExceeded):
+			return model.VerdictTimeLimit
+		case errors.Is(result.Error, executor.ErrMemoryLimitExceeded):
+			return model.VerdictMemoryLimit
+		case errors.Is(result.Error, executor.ErrJobQueueFull), errors.Is(result.Error, executor.ErrNoContainer):
+			// The engine turned the run away; the program never ran
+			return model.VerdictJudgeFailure
+		}
+		return model.VerdictRuntimeError
+	}
+
+	if !result.Success {
+		return model.VerdictRuntimeError
+	}
+
+	verdict, err := outputVerdict(cmp, expected, actual)
+	if err != nil {
+		return model.VerdictJudgeFailure
+	}
+	return verdict
+}
+
+// problemComparator returns how a problem's outputs are compared; function problems
+// always compare the values their functions return
+func problemComparator(problem *model.Problem) model.Comparator {
+	cmp := problem.Comparator
+	if problem.Function != nil {
+		cmp.Mode = model.CompareValues
+	}
+	return cmp
+}
+
+// problemLimits returns a problem's per-test limits for a language version, scaled by
+// the multiplier for "language@version" or else for the language
+func problemLimits(problem *model.Problem, language, version string) (time.Duration, int64) {
+	timeLimit := float64(problem.TimeLimit)
+	memoryLimit := float64(problem.MemoryLimit)
+
+	multiplier, ok := problem.LimitMultipliers[language+"@"+version]
+	if !ok {
+		multiplier = problem.LimitMultipliers[language]
+	}
+	if multiplier.Time > 0 {
+		timeLimit *= multiplier.Time
+	}
+	if multiplier.Memory > 0 {
+		memoryLimit *= multiplier.Memory
+	}
+	return time.Duration(timeLimit) * time.Millisecond, int64(memoryLimit)
+}
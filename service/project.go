@@ -0,0 +1,152 @@
+package service
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"path"
+	"strings"
+
+	"xcodeengine/executor"
+	"xcodeengine/internal"
+	"xcodeengine/model"
+)
+
+const (
+	maxProjectFiles = 64
+	maxProjectBytes = 1 << 20
+)
+
+var ErrProjectTooLarge = errors.New("project exceeds size limits")
+
+// prepareSource sanitizes a submission and returns its project files; a nil
+// slice means the submission is the single-file code
+func prepareSource(code, language string, project model.ProjectFiles, maxCodeLength int64) ([]executor.File, error) {
+	files, err := projectFiles(project)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(files) == 0 {
+		return nil, internal.SanitizeCode(code, language, maxCodeLength)
+	}
+
+	for _, f := range files {
+		if err := internal.SanitizeCode(f.Content, language, maxCodeLength); err != nil {
+			return nil, fmt.Errorf("%s: %w", f.Name, err)
+		}
+	}
+	return files, nil
+}
+
+// projectFiles collects the explicit files and the unpacked archive of a submission
+func projectFiles(project model.ProjectFiles) ([]executor.File, error) {
+	var files []executor.File
+	for _, f := range project.Files {
+		files = append(files, executor.File{Name: f.Name, Content: f.Content})
+	}
+
+	if project.Archive != "" {
+		data, err := base64.StdEncoding.DecodeString(project.Archive)
+		if err != nil {
+			return nil, fmt.Errorf("failed to decode archive: %v", err)
+		}
+		unpacked, err := unpackArchive(data)
+		if err != nil {
+			return nil, err
+		}
+		files = append(files, unpacked...)
+	}
+
+	if len(files) > maxProjectFiles {
+		return nil, fmt.Errorf("%w: at most %d files", ErrProjectTooLarge, maxProjectFiles)
+	}
+	total := 0
+	for i, f := range files {
+		files[i].Name = strings.TrimPrefix(path.Clean(f.Name), "./")
+		if err := executor.ValidateFileName(files[i].Name); err != nil {
+			return nil, err
+		}
+		total += len(f.Content)
+	}
+	if total > maxProjectBytes {
+		return nil, fmt.Errorf("%w: at most %d bytes", ErrProjectTooLarge, maxProjectBytes)
+	}
+	return files, nil
+}
+
+// unpackArchive reads the regular files of a zip, tar or gzipped tar archive
+func unpackArchive(data []byte) ([]executor.File, error) {
+	switch {
+	case bytes.HasPrefix(data, []byte("PK\x03\x04")):
+		return unpackZip(data)
+	case bytes.HasPrefix(data, []byte{0x1f, 0x8b}):
+		gz, err := gzip.NewReader(bytes.NewReader(data))
+		if err != nil {
+			return nil, fmt.Errorf("failed to read gzip archive: %v", err)
+		}
+		defer gz.Close()
+		return unpackTar(gz)
+	default:
+		return unpackTar(bytes.NewReader(data))
+	}
+}
+
+func unpackZip(data []byte) ([]executor.File, error) {
+	zr, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read zip archive: %v", err)
+	}
+
+	var files []executor.File
+	for _, zf := range zr.File {
+		if zf.FileInfo().IsDir() {
+			continue
+		}
+		if len(files) >= maxProjectFiles || zf.UncompressedSize64 > maxProjectBytes {
+			return nil, ErrProjectTooLarge
+		}
+		rc, err := zf.Open()
+		if err != nil {
+			return nil, fmt.Errorf("failed to open %s: %v", zf.Name, err)
+		}
+		content, err := io.ReadAll(io.LimitReader(rc, maxProjectBytes+1))
+		rc.Close()
+		if err != nil {
+			return nil, fmt.Errorf("failed to read %s: %v", zf.Name, err)
+		}
+		files = append(files, executor.File{Name: zf.Name, Content: string(content)})
+	}
+	return files, nil
+}
+
+func unpackTar(r io.Reader) ([]executor.File, error) {
+	tr := tar.NewReader(r)
+
+	var files []executor.File
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			return files, nil
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to read tar archive: %v", err)
+		}
+		if hdr.Typeflag != tar.TypeReg {
+			continue
+		}
+		if len(files) >= maxProjectFiles || hdr.Size > maxProjectBytes {
+			return nil, ErrProjectTooLarge
+		}
+		content, err := io.ReadAll(io.LimitReader(tr, maxProjectBytes+1))
+		if err != nil {
+			return nil, fmt.Errorf("failed to read %s: %v", hdr.Name, err)
+		}
+		files = append(files, executor.File{Name: hdr.Name, Content: string(content)})
+	}
+}
@@ -0,0 +1,123 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+
+	"xcodeengine/executor"
+	"xcodeengine/model"
+	"xcodeengine/problems"
+)
+
+// ErrNoReferenceSolution is returned when generating outputs for a problem without one
+var ErrNoReferenceSolution = errors.New("problem has no reference solution")
+
+// GenerateExpectedOutputs runs a problem's reference solution on every test input,
+// under the problem's limits for its language, and saves the outputs as a new problem
+// version. Tests where the reference fails are flagged and keep their old output.
+func (s *CompilerService) GenerateExpectedOutputs(problemID string) (*model.OutputGenerationResponse, error) {
+	store := problems.Active()
+	problem, ok := store.Get(problemID)
+	if !ok {
+		return nil, ErrProblemNotFound
+	}
+	if problem.Solution == nil {
+		return nil, ErrNoReferenceSolution
+	}
+	if problem.Interactor != nil {
+		return nil, fmt.Errorf("interactive problems have no expected outputs")
+	}
+
+	language, version := parseLanguage(problem.Solution.Language)
+	config, ok := executor.GetLanguageConfig(language)
+	if !ok {
+		return nil, fmt.Errorf("unsupported solution language: %s", problem.Solution.Language)
+	}
+	resolved, err := executor.ResolveVersion(language, version)
+	if err != nil {
+		return nil, err
+	}
+	timeLimit, memoryLimit := problemLimits(problem, language, resolved.Name)
+	files, entrypoint := []executor.File{{Name: config.SourceFile, Content: problem.Solution.Source}}, config.SourceFile
+	var marker string
+	if problem.Function != nil {
+		if files, entrypoint, marker, err = functionHarness(problem.Function, language, problem.Solution.Source); err != nil {
+			return nil, err
+		}
+	}
+
+	jobs := make([]executor.Job, len(problem.TestCases))
+	for i, tc := range problem.TestCases {
+		jobs[i] = executor.Job{
+			Language:    language,
+			Version:     resolved.Name,
+			Files:       files,
+			Entrypoint:  entrypoint,
+			Input:       tc.Input,
+			TimeLimit:   timeLimit,
+			MemoryLimit: memoryLimit,
+		}
+	}
+	outputs := s.runJobs(jobs)
+
+	response := &model.OutputGenerationResponse{ProblemID: problem.ID, ProblemVersion: problem.Version}
+	updated := *problem
+	updated.TestCases = append([]model.TestCase(nil), problem.TestCases...)
+	for i, result := range outputs {
+		tc := &updated.TestCases[i]
+		// Only stdout is the expected output; the reference may log to stderr
+		output := result.Stdout
+		if marker != "" {
+			output = functionValue(result.Stdout, marker)
+		}
+		report := model.GeneratedOutput{
+			Name:          tc.Name,
+			Status:        determineStatus(result, model.Comparator{}, "", ""),
+			ExecutionTime: result.ExecutionTime,
+		}
+		if result.Error != nil {
+			report.Error = result.Error.Error()
+		}
+		if report.Status != model.VerdictAccepted {
+			report.Flagged = true
+		} else if strings.TrimSpace(tc.ExpectedOutput) != strings.TrimSpace(output) {
+			tc.ExpectedOutput = output
+			report.Changed = true
+			response.Updated++
+		}
+		response.Tests = append(response.Tests, report)
+	}
+
+	if response.Updated > 0 {
+		saved, err := store.Update(updated)
+		if err != nil {
+			return nil, err
+		}
+		response.ProblemVersion = saved.Version
+	}
+	return response, nil
+}
+
+// runJobs runs jobs of one language version side by side, as many at a time as a
+// submission's tests, and returns their results in order
+func (s *CompilerService) runJobs(jobs []executor.Job) []executor.Result {
+	results := make([]executor.Result, len(jobs))
+	if len(jobs) == 0 {
+		return results
+	}
+	sem := make(chan struct{}, s.WorkerPool.JudgeParallelism(jobs[0].Language, jobs[0].Version))
+	var wg sync.WaitGroup
+	for i, job := range jobs {
+		sem <- struct{}{}
+		wg.Add(1)
+		go func(i int, job executor.Job) {
+			defer wg.Done()
+			defer func() { <-sem }()
+			results[i] = s.WorkerPool.ExecuteJobWait(job)
+		}(i, job)
+	}
+	wg.Wait()
+	return results
+}
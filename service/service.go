@@ -3,13 +3,10 @@ package service
 import (
 	"encoding/base64"
 	"errors"
-	"fmt"
 	"strings"
 	"time"
 	"xcodeengine/executor"
-	"xcodeengine/internal"
 	"xcodeengine/model"
-	"xcodeengine/problems"
 
 	compilergrpc "github.com/lijuuu/GlobalProtoXcode/Compiler"
 )
@@ -18,6 +15,9 @@ var (
 	ErrInvalidRequest  = errors.New("invalid request parameters")
 	ErrCodeTooLong     = errors.New("code exceeds maximum length")
 	ErrProblemNotFound = errors.New("problem not found")
+	ErrProblemArchived = errors.New("problem is archived")
+	ErrProblemDraft    = errors.New("problem is not published")
+	ErrTestsNotReady   = errors.New("problem tests have not been generated from its test script")
 )
 
 type CompilerRequest struct {
@@ -33,6 +33,13 @@ type CompilerResponse struct {
 	ExecutionTime string `json:"execution_time,omitempty"`
 }
 
+// CompileResult is a compiler response in the wire format NATS callers receive, with
+// the exact language version used, e.g. cpp@gnu++17, which that format has no field for
+type CompileResult struct {
+	*compilergrpc.CompileResponse
+	Version string
+}
+
 type CompilerService struct {
 	WorkerPool *executor.WorkerPool
 }
@@ -43,215 +50,180 @@ func NewCompilerService(workerPool *executor.WorkerPool) *CompilerService {
 	}
 }
 
-func normalizeLanguage(lang string) string {
-
-	lang = strings.ToLower(lang)
-
-	languageMap := map[string]string{
-
-		"js":          "js",
-		"jscript":     "js",
-		"javscript":   "js",
-		"javsscript":  "js",
-		"javascipt":   "js",
-		"javasript":   "js",
-		"javascript":  "js",
-		"java script": "js",
-		"jscipt":      "js",
-
-		"python":  "python",
-		"pyt":     "python",
-		"pyn":     "python",
-		"pythn":   "python",
-		"phyton":  "python",
-		"py":      "python",
-		"py thon": "python",
-		"pthon":   "python",
-
-		"go":      "go",
-		"golang":  "go",
-		"gol":     "go",
-		"goo":     "go",
-		"g o":     "go",
-		"golangg": "go",
-
-		"cpp":    "cpp",
-		"c++":    "cpp",
-		"cp":     "cpp",
-		"cppp":   "cpp",
-		"c plus": "cpp",
-		"cxx":    "cpp",
-		"cc":     "cpp",
-		"cpp ":   "cpp",
-
-		"c":     "c",
-		" c":    "c",
-		"c ":    "c",
-		"clang": "c",
-
-		"java":   "java",
-		"jav":    "java",
-		"jvaa":   "java",
-		"java11": "java",
-		"java17": "java",
-	}
-
-	if normalized, ok := languageMap[lang]; ok {
-		return normalized
+// parseLanguage splits a language identifier such as "cpp@gnu++20" into its
+// normalized language and version; an empty version selects the default
+func parseLanguage(lang string) (string, string) {
+	base, version, _ := strings.Cut(strings.TrimSpace(lang), "@")
+	language, implied, _ := strings.Cut(executor.NormalizeLanguage(base), "@")
+	if version == "" {
+		version = implied
 	}
+	return language, strings.ToLower(strings.TrimSpace(version))
+}
 
-	return lang
+func (s *CompilerService) Compile(code string, language string, stdin string, flags []string, project model.ProjectFiles) (*CompileResult, error) {
+	return s.CompileStream(code, language, stdin, flags, project, nil)
 }
 
-func (s *CompilerService) Compile(code string, language string, stdin string) (*compilergrpc.CompileResponse, error) {
+// CompileStream is Compile with output chunks pushed to onOutput while the program runs
+func (s *CompilerService) CompileStream(code string, language string, stdin string, flags []string, project model.ProjectFiles, onOutput func(executor.OutputChunk)) (*CompileResult, error) {
 	start := time.Now()
 
 	// Normalize the language string
-	language = normalizeLanguage(language)
+	language, version := parseLanguage(language)
 
 	codeBytes, err := base64.StdEncoding.DecodeString(code)
 	if err != nil {
-		return &compilergrpc.CompileResponse{
+		return &CompileResult{CompileResponse: &compilergrpc.CompileResponse{
 			Success:       false,
 			Error:         err.Error(),
 			StatusMessage: "Failed to decode base64",
-		}, nil
+		}}, nil
 	}
 
 	code = string(codeBytes)
 
 	// Sanitize code
-	if err := internal.SanitizeCode(code, language, 10000); err != nil {
-		return &compilergrpc.CompileResponse{
+	files, err := prepareSource(code, language, project, 10000)
+	if err != nil {
+		return &CompileResult{CompileResponse: &compilergrpc.CompileResponse{
 			Success:       false,
 			Error:         err.Error(),
 			StatusMessage: err.Error(),
-		}, nil
+		}}, nil
+	}
+
+	// Reject flags outside the language allowlist
+	if _, _, _, err := executor.ResolveFlags(language, version, flags); err != nil {
+		return &CompileResult{CompileResponse: &compilergrpc.CompileResponse{
+			Success:       false,
+			Error:         err.Error(),
+			StatusMessage: "Flag not allowed",
+		}}, nil
 	}
 
 	// fmt.Println(code)
 
 	// Execute code using worker pool
-	result := s.WorkerPool.ExecuteJob(language, code, stdin)
+	result := s.WorkerPool.ExecuteJob(executor.Job{
+		Language:   language,
+		Version:    version,
+		Flags:      flags,
+		Code:       code,
+		Files:      files,
+		Entrypoint: project.Entrypoint,
+		Input:      stdin,
+		Stream:     onOutput,
+	})
 
 	if result.Error != nil {
-		return &compilergrpc.CompileResponse{
-			Success:       false,
-			Error:         result.Error.Error(),
-			Output:        result.Output,
-			StatusMessage: "Failed to execute code",
+		return &CompileResult{
+			CompileResponse: &compilergrpc.CompileResponse{
+				Success:       false,
+				Error:         result.Error.Error(),
+				Output:        result.Output,
+				StatusMessage: "Failed to execute code",
+			},
+			Version: result.Version,
 		}, nil
 	}
 
-	return &compilergrpc.CompileResponse{
-		Success:       true,
-		Output:        result.Output,
-		ExecutionTime: time.Since(start).String(),
-		StatusMessage: "Success",
+	return &CompileResult{
+		CompileResponse: &compilergrpc.CompileResponse{
+			Success:       true,
+			Output:        result.Output,
+			ExecutionTime: time.Since(start).String(),
+			StatusMessage: "Success",
+		},
+		Version: result.Version,
 	}, nil
 }
 
-func (s *CompilerService) ExecuteProblemCode(code string, language string) (*compilergrpc.CompileResponse, error) {
+func (s *CompilerService) ExecuteProblemCode(code string, language string, flags []string, project model.ProjectFiles) (*CompileResult, error) {
+	return s.ExecuteProblemCodeStream(code, language, flags, project, nil)
+}
+
+// ExecuteProblemCodeStream is ExecuteProblemCode with output chunks pushed to onOutput while the program runs
+func (s *CompilerService) ExecuteProblemCodeStream(code string, language string, flags []string, project model.ProjectFiles, onOutput func(executor.OutputChunk)) (*CompileResult, error) {
 	start := time.Now()
 
 	// Normalize the language string
-	language = normalizeLanguage(language)
+	language, version := parseLanguage(language)
 
 	// fmt.Println("Normalized language:", language)
 	// fmt.Println("Code:", code)
 
 	// Sanitize code
-	if err := internal.SanitizeCode(code, language, 1000000000000); err != nil {
-		return &compilergrpc.CompileResponse{
+	files, err := prepareSource(code, language, project, 1000000000000)
+	if err != nil {
+		return &CompileResult{CompileResponse: &compilergrpc.CompileResponse{
 			Success:       false,
 			Output:        "",
 			Error:         err.Error(),
 			StatusMessage: err.Error(),
-		}, nil
+		}}, nil
+	}
+
+	// Reject flags outside the language allowlist
+	if _, _, _, err := executor.ResolveFlags(language, version, flags); err != nil {
+		return &CompileResult{CompileResponse: &compilergrpc.CompileResponse{
+			Success:       false,
+			Error:         err.Error(),
+			StatusMessage: "Flag not allowed",
+		}}, nil
 	}
 
 	// Execute code using worker pool
-	result := s.WorkerPool.ExecuteJob(language, code, "")
-	fmt.Println("Execution result:", result)
+	result := s.WorkerPool.ExecuteJob(executor.Job{
+		Language:   language,
+		Version:    version,
+		Flags:      flags,
+		Code:       code,
+		Files:      files,
+		Entrypoint: project.Entrypoint,
+		Stream:     onOutput,
+	})
 
 	if result.Error != nil {
-		return &compilergrpc.CompileResponse{
-			Success:       false,
-			Error:         result.Error.Error(),
-			Output:        result.Output,
-			StatusMessage: "Failed to execute code",
+		return &CompileResult{
+			CompileResponse: &compilergrpc.CompileResponse{
+				Success:       false,
+				Error:         result.Error.Error(),
+				Output:        result.Output,
+				StatusMessage: "Failed to execute code",
+			},
+			Version: result.Version,
 		}, nil
 	}
 
 	// fmt.Println("Output:", result.Output)
 
-	return &compilergrpc.CompileResponse{
-		Success:       true,
-		Output:        result.Output,
-		ExecutionTime: time.Since(start).String(),
-		StatusMessage: "Success",
+	return &CompileResult{
+		CompileResponse: &compilergrpc.CompileResponse{
+			Success:       true,
+			Output:        result.Output,
+			ExecutionTime: time.Since(start).String(),
+			StatusMessage: "Success",
+		},
+		Version: result.Version,
 	}, nil
 }
 
-func (s *CompilerService) JudgeProblem(code, language, problemID string) (*model.JudgeResponse, error) {
-	problem, ok := problems.GetProblem(problemID)
-	if !ok {
-		return nil, ErrProblemNotFound
-	}
+// StartSession sanitizes code and starts it as an interactive session on the worker pool
+func (s *CompilerService) StartSession(code, language string, flags []string, project model.ProjectFiles) (*executor.Session, error) {
+	language, version := parseLanguage(language)
 
-	language = normalizeLanguage(language)
-	if err := internal.SanitizeCode(code, language, 1000000); err != nil {
+	files, err := prepareSource(code, language, project, 1000000)
+	if err != nil {
 		return nil, err
 	}
 
-	response := &model.JudgeResponse{
-		ProblemID: problem.ID,
-		Verdict:   "AC",
-	}
-
-	for _, tc := range problem.TestCases {
-		execResult := s.WorkerPool.ExecuteJob(language, code, tc.Input)
-
-		caseResult := model.TestCaseResult{
-			Name:          tc.Name,
-			Input:         tc.Input,
-			Expected:      strings.TrimSpace(tc.ExpectedOutput),
-			Output:        strings.TrimSpace(execResult.Output),
-			ExecutionTime: execResult.ExecutionTime,
-		}
-
-		status := determineStatus(execResult, strings.TrimSpace(tc.ExpectedOutput), caseResult.Output)
-		caseResult.Status = status
-
-		if execResult.Error != nil {
-			caseResult.Error = execResult.Error.Error()
-		}
-
-		if status != "AC" && response.Verdict == "AC" {
-			response.Verdict = status
-		}
-
-		response.Results = append(response.Results, caseResult)
-	}
-
-	return response, nil
-}
-
-func determineStatus(result executor.Result, expected, actual string) string {
-	if result.Error != nil {
-		if strings.Contains(result.Error.Error(), "context deadline exceeded") {
-			return "TLE"
-		}
-		return "RE"
-	}
-
-	if !result.Success {
-		return "RE"
-	}
-
-	if expected != actual {
-		return "WA"
-	}
-
-	return "AC"
+	return s.WorkerPool.StartSession(executor.Job{
+		Language:   language,
+		Version:    version,
+		Flags:      flags,
+		Code:       code,
+		Files:      files,
+		Entrypoint: project.Entrypoint,
+	})
 }
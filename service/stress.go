@@ -0,0 +1,153 @@
+package service
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"xcodeengine/executor"
+	"xcodeengine/internal"
+	"xcodeengine/model"
+)
+
+// Bounds on the seeds one stress test tries
+const (
+	defaultStressRuns = 100
+	maxStressRuns     = 1000
+)
+
+// Stress runs a generator with increasing seeds and feeds each input to a solution
+// and a brute force, stopping at the first input where their outputs differ. Seeds
+// run through the worker pool in batches as wide as a submission's tests. A generator
+// or brute force that fails, or a solution that does not build, is an error rather
+// than a finding, since such a run says nothing about the solution.
+func (s *CompilerService) Stress(req model.StressRequest) (*model.StressResponse, error) {
+	runs := req.MaxRuns
+	if runs <= 0 {
+		runs = defaultStressRuns
+	}
+	if runs > maxStressRuns {
+		return nil, fmt.Errorf("max_runs is at most %d", maxStressRuns)
+	}
+	seed := req.StartSeed
+	if seed == 0 {
+		seed = 1
+	}
+	for _, arg := range req.Args {
+		if err := executor.ValidateArg(arg); err != nil {
+			return nil, err
+		}
+	}
+	if _, err := compareOutput(req.Comparator, "", ""); err != nil {
+		return nil, err
+	}
+	timeLimit := time.Duration(req.TimeLimitMs) * time.Millisecond
+
+	generator, err := stressJob(req.Generator, "generator", 0)
+	if err != nil {
+		return nil, err
+	}
+	solution, err := stressJob(req.Solution, "solution", timeLimit)
+	if err != nil {
+		return nil, err
+	}
+	brute, err := stressJob(req.BruteForce, "brute force", timeLimit)
+	if err != nil {
+		return nil, err
+	}
+
+	response := &model.StressResponse{}
+	batch := s.WorkerPool.JudgeParallelism(solution.Language, solution.Version)
+	for response.Runs < runs {
+		n := min(batch, runs-response.Runs)
+		seeds := make([]int64, n)
+		generators := make([]executor.Job, n)
+		for i := range generators {
+			seeds[i] = seed + int64(response.Runs+i)
+			generators[i] = generator
+			generators[i].Args = append(append([]string(nil), req.Args...), strconv.FormatInt(seeds[i], 10))
+		}
+
+		// The solution's runs come first, then the brute force's, all side by side
+		runsOf := make([]executor.Job, 2*n)
+		for i, result := range s.runJobs(generators) {
+			if result.Error != nil || !result.Success {
+				return nil, fmt.Errorf("generator failed with seed %d: %s", seeds[i], runFailure(result))
+			}
+			runsOf[i], runsOf[n+i] = solution, brute
+			// Only stdout is the test; the generator may log to stderr
+			runsOf[i].Input, runsOf[n+i].Input = result.Stdout, result.Stdout
+		}
+		results := s.runJobs(runsOf)
+
+		for i := range n {
+			expected, actual := results[n+i], results[i]
+			if status := determineStatus(expected, model.Comparator{}, "", ""); status != model.VerdictAccepted {
+				return nil, fmt.Errorf("brute force failed with seed %d (%s): %s", seeds[i], status, runFailure(expected))
+			}
+			// Both programs are judged on stdout alone, so either may log to stderr
+			status := determineStatus(actual, req.Comparator, expected.Stdout, actual.Stdout)
+			if status == model.VerdictCompilationError {
+				return nil, fmt.Errorf("solution failed to build: %s", runFailure(actual))
+			}
+			if status == model.VerdictAccepted {
+				continue
+			}
+			response.Found = true
+			response.Runs += i + 1
+			response.Seed = seeds[i]
+			response.Input = runsOf[i].Input
+			response.Output = actual.Stdout
+			response.Expected = expected.Stdout
+			response.Status = status
+			if status == model.VerdictWrongAnswer || status == model.VerdictPresentationError {
+				response.Diff = diffOutputs(strings.TrimSpace(expected.Stdout), strings.TrimSpace(actual.Stdout))
+			}
+			return response, nil
+		}
+		response.Runs += n
+	}
+	return response, nil
+}
+
+// stressJob builds the job that runs one program of a stress test; role names it in errors
+func stressJob(program model.StressProgram, role string, timeLimit time.Duration) (executor.Job, error) {
+	language, version := parseLanguage(program.Language)
+	if language == "" || strings.TrimSpace(program.Code) == "" {
+		return executor.Job{}, fmt.Errorf("%s: code and language are required", role)
+	}
+	if err := internal.SanitizeCode(program.Code, language, 1000000); err != nil {
+		return executor.Job{}, fmt.Errorf("%s: %w", role, err)
+	}
+	version, _, _, err := executor.ResolveFlags(language, version, program.Flags)
+	if err != nil {
+		return executor.Job{}, fmt.Errorf("%s: %w", role, err)
+	}
+	resolved, err := executor.ResolveVersion(language, version)
+	if err != nil {
+		return executor.Job{}, fmt.Errorf("%s: %w", role, err)
+	}
+	return executor.Job{
+		Language:  language,
+		Version:   resolved.Name,
+		Flags:     program.Flags,
+		Code:      program.Code,
+		TimeLimit: timeLimit,
+	}, nil
+}
+
+// runFailure describes why a run failed, from its error and what it printed
+func runFailure(result executor.Result) string {
+	message := strings.TrimSpace(result.Output)
+	if len(message) > maxCheckerMessage {
+		message = message[:maxCheckerMessage] + "..."
+	}
+	if result.Error == nil {
+		return message
+	}
+	if message == "" {
+		return result.Error.Error()
+	}
+	return result.Error.Error() + ": " + message
+}
@@ -0,0 +1,183 @@
+package service
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"sync"
+	"time"
+
+	"xcodeengine/model"
+	"xcodeengine/submissions"
+)
+
+// submissionRetention is how long a finished submission stays available to poll
+const submissionRetention = time.Hour
+
+var (
+	ErrQueueFull          = errors.New("submission queue is full")
+	ErrSubmissionNotFound = errors.New("submission not found")
+)
+
+// queuedSubmission is a submission waiting for a judge
+type queuedSubmission struct {
+	id  string
+	req model.ProblemSubmissionRequest
+}
+
+// SubmissionQueue judges submissions in the background, a few at a time, and keeps
+// their state for clients to poll. Each judge runs a submission's tests on the worker
+// pool like a synchronous submission.
+type SubmissionQueue struct {
+	service     *CompilerService
+	queue       chan queuedSubmission
+	mu          sync.Mutex
+	submissions map[string]*model.Submission
+}
+
+// NewSubmissionQueue starts judges that take submissions from a queue of the given capacity
+func NewSubmissionQueue(compilerService *CompilerService, judges, capacity int) *SubmissionQueue {
+	judges = max(judges, 1)
+	capacity = max(capacity, 1)
+	q := &SubmissionQueue{
+		service:     compilerService,
+		queue:       make(chan queuedSubmission, capacity),
+		submissions: make(map[string]*model.Submission),
+	}
+	for range judges {
+		go q.judge()
+	}
+	return q
+}
+
+// Submit queues a submission and returns it in the queued state. Submissions to a
+// problem that takes none are refused at once.
+func (q *SubmissionQueue) Submit(req model.ProblemSubmissionRequest) (*model.Submission, error) {
+	if _, err := openProblem(req.ProblemID); err != nil {
+		return nil, err
+	}
+	submission, err := newSubmission(req)
+	if err != nil {
+		return nil, err
+	}
+	id := submission.ID
+
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.expire(submission.Created)
+	// Recorded first, so a judge that takes it at once finds it
+	q.submissions[id] = submission
+	select {
+	case q.queue <- queuedSubmission{id: id, req: req}:
+	default:
+		delete(q.submissions, id)
+		return nil, ErrQueueFull
+	}
+	return snapshot(submission), nil
+}
+
+// Get returns the current state of a submission. Submissions the queue no longer
+// holds come from the submission store, if any.
+func (q *SubmissionQueue) Get(id string) (*model.Submission, error) {
+	q.mu.Lock()
+	submission, ok := q.submissions[id]
+	if ok {
+		defer q.mu.Unlock()
+		return snapshot(submission), nil
+	}
+	q.mu.Unlock()
+
+	store := submissions.Active()
+	if store == nil {
+		return nil, ErrSubmissionNotFound
+	}
+	recorded, err := store.Get(id)
+	if errors.Is(err, submissions.ErrNotFound) {
+		return nil, ErrSubmissionNotFound
+	}
+	return recorded, err
+}
+
+// judge runs queued submissions one after another
+func (q *SubmissionQueue) judge() {
+	for item := range q.queue {
+		q.update(item.id, func(s *model.Submission) {
+			now := time.Now().UTC()
+			s.Status = model.SubmissionRunning
+			s.Started = &now
+		})
+
+		req := item.req
+		resp, err := q.service.JudgeProblemStream(req.Code, req.Language, req.ProblemID, req.JudgeMode, req.Flags, req.ProjectFiles, func(event model.JudgeEvent) {
+			q.update(item.id, func(s *model.Submission) { recordProgress(s, event) })
+		})
+
+		var finished *model.Submission
+		q.update(item.id, func(s *model.Submission) {
+			finishSubmission(s, resp, err)
+			if resp != nil {
+				resp.SubmissionID = s.ID
+			}
+			finished = snapshot(s)
+		})
+		if finished != nil {
+			recordSubmission(finished)
+		}
+	}
+}
+
+// update changes a submission under the queue lock
+func (q *SubmissionQueue) update(id string, change func(*model.Submission)) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if submission, ok := q.submissions[id]; ok {
+		change(submission)
+	}
+}
+
+// expire forgets submissions that finished longer than submissionRetention ago
+func (q *SubmissionQueue) expire(now time.Time) {
+	for id, submission := range q.submissions {
+		if submission.Finished != nil && now.Sub(*submission.Finished) > submissionRetention {
+			delete(q.submissions, id)
+		}
+	}
+}
+
+// recordProgress applies a judge event to a running submission's per-test state
+func recordProgress(s *model.Submission, event model.JudgeEvent) {
+	if event.Test < 1 || event.Test > event.Total {
+		return
+	}
+	if len(s.Tests) != event.Total {
+		s.Tests = make([]model.TestCaseResult, event.Total)
+		for i := range s.Tests {
+			s.Tests[i].Status = model.SubmissionQueued
+		}
+	}
+	switch event.Type {
+	case "test_started":
+		s.Tests[event.Test-1] = model.TestCaseResult{Name: event.Name, Status: model.SubmissionRunning}
+	case "test_finished":
+		if event.Result != nil {
+			s.Tests[event.Test-1] = *event.Result
+			s.Completed++
+		}
+	}
+}
+
+// snapshot copies a submission so it can be read outside the queue lock
+func snapshot(s *model.Submission) *model.Submission {
+	c := *s
+	c.Tests = append([]model.TestCaseResult(nil), s.Tests...)
+	return &c
+}
+
+// newSubmissionID returns a random submission ID
+func newSubmissionID() (string, error) {
+	b := make([]byte, 12)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
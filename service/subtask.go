@@ -0,0 +1,120 @@
+package service
+
+import (
+	"math"
+	"strconv"
+	"strings"
+
+	"xcodeengine/model"
+)
+
+// subtaskTests returns the indices of the tests that belong to any subtask
+func subtaskTests(subtasks []model.Subtask) map[int]bool {
+	tests := make(map[int]bool)
+	for _, subtask := range subtasks {
+		for _, test := range subtask.Tests {
+			tests[test-1] = true
+		}
+	}
+	return tests
+}
+
+// judgeSubtasks runs the subtasks in order and returns their results with the total
+// and maximum score. A subtask whose prerequisite fell short of full score is skipped.
+func (r *judgeRun) judgeSubtasks() ([]model.SubtaskResult, float64, float64) {
+	var total, maxScore float64
+	passed := make(map[string]bool, len(r.problem.Subtasks))
+	results := make([]model.SubtaskResult, 0, len(r.problem.Subtasks))
+
+	for _, subtask := range r.problem.Subtasks {
+		result := model.SubtaskResult{
+			Name:     subtask.Name,
+			Status:   model.VerdictAccepted,
+			MaxScore: subtask.Score,
+			Tests:    subtask.Tests,
+		}
+		maxScore += subtask.Score
+
+		for _, dep := range subtask.DependsOn {
+			if !passed[dep] {
+				result.Status = model.VerdictSkipped
+				break
+			}
+		}
+		if result.Status == model.VerdictSkipped {
+			results = append(results, result)
+			continue
+		}
+
+		indices := make([]int, 0, len(subtask.Tests))
+		for _, test := range subtask.Tests {
+			indices = append(indices, test-1)
+		}
+		r.runTests(indices)
+
+		points := make([]float64, 0, len(subtask.Tests))
+		for _, test := range subtask.Tests {
+			tc := r.results[test-1]
+			points = append(points, tc.Points)
+			if tc.Status != model.VerdictAccepted && result.Status == model.VerdictAccepted {
+				result.Status = tc.Status
+			}
+		}
+
+		result.Score = subtaskScore(subtask, points)
+		passed[subtask.Name] = result.Status == model.VerdictAccepted
+		total += result.Score
+		results = append(results, result)
+	}
+	return results, total, maxScore
+}
+
+// subtaskScore applies a subtask's scoring rule to the points of its tests
+func subtaskScore(subtask model.Subtask, points []float64) float64 {
+	switch subtask.Scoring {
+	case model.ScoringMin:
+		lowest := 1.0
+		for _, p := range points {
+			lowest = math.Min(lowest, p)
+		}
+		return subtask.Score * lowest
+	case model.ScoringSum:
+		sum := 0.0
+		for _, p := range points {
+			sum += p
+		}
+		return subtask.Score * sum / float64(len(points))
+	default:
+		for _, p := range points {
+			if p < 1 {
+				return 0
+			}
+		}
+		return subtask.Score
+	}
+}
+
+// testPoints returns the share of a test earned: all of it when accepted, the
+// checker's award for a partial verdict, and nothing otherwise
+func testPoints(status, message string) float64 {
+	switch status {
+	case model.VerdictAccepted:
+		return 1
+	case model.VerdictPartial:
+		return partialPoints(message)
+	}
+	return 0
+}
+
+// partialPoints reads the award of a testlib "points" comment, clamped to [0, 1]
+func partialPoints(message string) float64 {
+	fields := strings.Fields(message)
+	if len(fields) < 2 || fields[0] != "points" {
+		return 0
+	}
+	points, err := strconv.ParseFloat(fields[1], 64)
+	if err != nil || math.IsNaN(points) {
+		return 0
+	}
+	return math.Max(0, math.Min(1, points))
+}
@@ -0,0 +1,110 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"sync"
+
+	"xcodeengine/executor"
+	"xcodeengine/model"
+	"xcodeengine/problems"
+)
+
+// validatingPending keeps reloads that follow each other quickly from validating the
+// same problems side by side
+var validatingPending sync.Mutex
+
+// ValidateInputs runs a problem's validator on every test input in the sandbox and
+// reports the inputs it rejects. A problem without a validator is valid. The outcome
+// is recorded with the problem store, which publishes a problem held back for
+// validation once its inputs pass.
+func (s *CompilerService) ValidateInputs(problem *model.Problem) *model.InputValidationReport {
+	report := s.validateInputs(problem)
+	// A validator that failed to run says nothing either way
+	if report.Error == "" {
+		if err := problems.Active().RecordValidation(problem, report.Valid); err != nil {
+			log.Printf("Failed to record the validation of problem %s: %v", problem.ID, err)
+		}
+	}
+	return report
+}
+
+// ValidatePending validates every problem held back because its folder was published
+// before its inputs were validated. Problems whose inputs pass go live; the others
+// stay drafts until their inputs change, and the tests turned down are logged.
+func (s *CompilerService) ValidatePending() {
+	validatingPending.Lock()
+	defer validatingPending.Unlock()
+	for _, problem := range problems.Active().Pending() {
+		report := s.ValidateInputs(&problem)
+		switch {
+		case report.Error != "":
+			log.Printf("Problem %s stays unpublished: %s", problem.ID, report.Error)
+		case !report.Valid:
+			var rejected []string
+			for _, test := range report.Tests {
+				if !test.Valid {
+					rejected = append(rejected, test.Name)
+				}
+			}
+			log.Printf("Problem %s stays unpublished: its validator rejects tests %s", problem.ID, strings.Join(rejected, ", "))
+		default:
+			log.Printf("Problem %s passed validation and is published", problem.ID)
+		}
+	}
+}
+
+// validateInputs runs the validator and builds the report of ValidateInputs
+func (s *CompilerService) validateInputs(problem *model.Problem) *model.InputValidationReport {
+	report := &model.InputValidationReport{ProblemID: problem.ID, Valid: true}
+	if problem.Validator == nil {
+		return report
+	}
+
+	fail := func(err error) *model.InputValidationReport {
+		report.Valid = false
+		report.Error = err.Error()
+		report.Tests = nil
+		return report
+	}
+	language, version := parseLanguage(problem.Validator.Language)
+	config, ok := executor.GetLanguageConfig(language)
+	if !ok {
+		return fail(fmt.Errorf("unsupported validator language: %s", problem.Validator.Language))
+	}
+	resolved, err := executor.ResolveVersion(language, version)
+	if err != nil {
+		return fail(err)
+	}
+
+	jobs := make([]executor.Job, len(problem.TestCases))
+	for i, tc := range problem.TestCases {
+		jobs[i] = executor.Job{
+			Language:   language,
+			Version:    resolved.Name,
+			Files:      []executor.File{{Name: config.SourceFile, Content: problem.Validator.Source}},
+			Entrypoint: config.SourceFile,
+			Input:      tc.Input,
+		}
+	}
+
+	for i, result := range s.runJobs(jobs) {
+		// A validator that cannot build or finish says nothing about the input
+		if errors.Is(result.Error, executor.ErrBuildFailed) || errors.Is(result.Error, executor.ErrTimeLimitExceeded) ||
+			errors.Is(result.Error, executor.ErrMemoryLimitExceeded) || result.ExitCode < 0 {
+			return fail(fmt.Errorf("validator failed on test %d: %v: %s", i+1, result.Error, strings.TrimSpace(result.Output)))
+		}
+		check := model.InputValidation{Name: problem.TestCases[i].Name, Valid: result.ExitCode == 0}
+		if !check.Valid {
+			check.Message = strings.TrimSpace(result.Output)
+			if len(check.Message) > maxCheckerMessage {
+				check.Message = check.Message[:maxCheckerMessage] + "..."
+			}
+			report.Valid = false
+		}
+		report.Tests = append(report.Tests, check)
+	}
+	return report
+}
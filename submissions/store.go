@@ -0,0 +1,201 @@
+package submissions
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sync/atomic"
+	"time"
+
+	bolt "go.etcd.io/bbolt"
+
+	"xcodeengine/model"
+)
+
+// Buckets of the store: submissions by ID, and summaries indexed by submitter and by
+// problem. Index keys are the owner, a zero byte, the creation time and the ID, so a
+// cursor walks one owner's submissions in time order.
+var (
+	submissionsBucket = []byte("submissions")
+	bySubmitterBucket = []byte("by_submitter")
+	byProblemBucket   = []byte("by_problem")
+)
+
+// Bounds on a history page
+const (
+	DefaultLimit = 50
+	MaxLimit     = 500
+)
+
+var ErrNotFound = errors.New("submission not found")
+
+// Store keeps judged submissions in a bbolt file, so that they survive restarts
+type Store struct {
+	db *bolt.DB
+}
+
+// Query selects a page of history: at most Limit submissions created before the
+// submission named by Before, newest first
+type Query struct {
+	Limit  int
+	Before string
+}
+
+// active is the store that judged submissions are recorded in; nil keeps none
+var active atomic.Pointer[Store]
+
+// Use makes store the one that judged submissions are recorded in
+func Use(store *Store) {
+	active.Store(store)
+}
+
+// Active returns the store that judged submissions are recorded in, or nil
+func Active() *Store {
+	return active.Load()
+}
+
+// Open opens or creates the store file at path
+func Open(path string) (*Store, error) {
+	db, err := bolt.Open(path, 0o600, &bolt.Options{Timeout: 5 * time.Second})
+	if err != nil {
+		return nil, fmt.Errorf("failed to open submission store: %v", err)
+	}
+	err = db.Update(func(tx *bolt.Tx) error {
+		for _, name := range [][]byte{submissionsBucket, bySubmitterBucket, byProblemBucket} {
+			if _, err := tx.CreateBucketIfNotExists(name); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		db.Close()
+		return nil, fmt.Errorf("failed to prepare submission store: %v", err)
+	}
+	return &Store{db: db}, nil
+}
+
+// Close closes the store file
+func (s *Store) Close() error {
+	return s.db.Close()
+}
+
+// Put records a submission, replacing an earlier record with the same ID
+func (s *Store) Put(submission *model.Submission) error {
+	data, err := json.Marshal(submission)
+	if err != nil {
+		return err
+	}
+	summary, err := json.Marshal(submission.Summary())
+	if err != nil {
+		return err
+	}
+	return s.db.Update(func(tx *bolt.Tx) error {
+		if err := tx.Bucket(submissionsBucket).Put([]byte(submission.ID), data); err != nil {
+			return err
+		}
+		if submission.Submitter != "" {
+			key := indexKey(submission.Submitter, submission.Created, submission.ID)
+			if err := tx.Bucket(bySubmitterBucket).Put(key, summary); err != nil {
+				return err
+			}
+		}
+		key := indexKey(submission.ProblemID, submission.Created, submission.ID)
+		return tx.Bucket(byProblemBucket).Put(key, summary)
+	})
+}
+
+// Get returns a recorded submission in full
+func (s *Store) Get(id string) (*model.Submission, error) {
+	var submission model.Submission
+	err := s.db.View(func(tx *bolt.Tx) error {
+		data := tx.Bucket(submissionsBucket).Get([]byte(id))
+		if data == nil {
+			return ErrNotFound
+		}
+		return json.Unmarshal(data, &submission)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &submission, nil
+}
+
+// BySubmitter returns a page of one submitter's history, newest first. A problem ID
+// narrows it to that problem.
+func (s *Store) BySubmitter(submitter, problemID string, q Query) (*model.SubmissionHistory, error) {
+	return s.history(bySubmitterBucket, submitter, q, func(summary model.SubmissionSummary) bool {
+		return problemID == "" || summary.ProblemID == problemID
+	})
+}
+
+// ByProblem returns a page of the history of one problem, newest first
+func (s *Store) ByProblem(problemID string, q Query) (*model.SubmissionHistory, error) {
+	return s.history(byProblemBucket, problemID, q, func(model.SubmissionSummary) bool { return true })
+}
+
+// history walks an index backwards from the query's cursor and collects the summaries
+// of one owner that match
+func (s *Store) history(bucket []byte, owner string, q Query, match func(model.SubmissionSummary) bool) (*model.SubmissionHistory, error) {
+	limit := q.Limit
+	if limit <= 0 {
+		limit = DefaultLimit
+	}
+	limit = min(limit, MaxLimit)
+
+	history := &model.SubmissionHistory{Submissions: []model.SubmissionSummary{}}
+	err := s.db.View(func(tx *bolt.Tx) error {
+		prefix := append([]byte(owner), 0)
+		// The first key past the owner's entries; cursors step back from there
+		end := append([]byte(owner), 1)
+		if q.Before != "" {
+			data := tx.Bucket(submissionsBucket).Get([]byte(q.Before))
+			if data == nil {
+				return ErrNotFound
+			}
+			var before model.Submission
+			if err := json.Unmarshal(data, &before); err != nil {
+				return err
+			}
+			end = indexKey(owner, before.Created, before.ID)
+		}
+
+		c := tx.Bucket(bucket).Cursor()
+		k, v := c.Seek(end)
+		if k == nil {
+			k, v = c.Last()
+		} else {
+			k, v = c.Prev()
+		}
+		for ; k != nil && bytes.HasPrefix(k, prefix); k, v = c.Prev() {
+			var summary model.SubmissionSummary
+			if err := json.Unmarshal(v, &summary); err != nil {
+				return err
+			}
+			if !match(summary) {
+				continue
+			}
+			if len(history.Submissions) == limit {
+				history.Next = history.Submissions[limit-1].ID
+				break
+			}
+			history.Submissions = append(history.Submissions, summary)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return history, nil
+}
+
+// indexKey orders an owner's submissions by creation time, then ID
+func indexKey(owner string, created time.Time, id string) []byte {
+	key := make([]byte, 0, len(owner)+1+8+len(id))
+	key = append(key, owner...)
+	key = append(key, 0)
+	key = binary.BigEndian.AppendUint64(key, uint64(created.UnixNano()))
+	return append(key, id...)
+}
@@ -155,10 +155,19 @@ function setActiveProblem(problemId) {
   problemSelect.value = activeProblem.id;
   renderProblemDescription();
   problemConstraints.textContent = `Input: ${activeProblem.input_format} • Constraints: ${activeProblem.constraints}`;
+  if (activeProblem.function) {
+    problemConstraints.textContent += ` • Function: ${functionSignature(activeProblem.function)}`;
+  }
   verdictEl.textContent = "";
   testResultsList.innerHTML = "";
 }
 
+// functionSignature renders a function-signature problem's function, e.g. "twoSum(nums: int[], target: int) -> int[]"
+function functionSignature(fn) {
+  const params = (fn.params || []).map((p) => `${p.name}: ${p.type}`).join(", ");
+  return `${fn.name}(${params}) -> ${fn.returns}`;
+}
+
 function renderProblemDescription() {
   if (!activeProblem) {
     return;